@@ -0,0 +1,267 @@
+package fspath
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/stealthrocket/fslink"
+)
+
+// ZipFS returns a file system exposing the content of the zip archive r, with
+// support for reading the symbolic links that it contains.
+//
+// The zip.Reader type implements fs.FS but ignores symbolic links, which are
+// stored as regular entries with fs.ModeSymlink set and the link target as
+// content. The returned file system implements fslink.ReadLinkFS to surface
+// them to Lookup.
+func ZipFS(r *zip.Reader) fs.FS { return zipFS{r} }
+
+type zipFS struct{ *zip.Reader }
+
+func (fsys zipFS) ReadLink(name string) (string, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	s, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	if s.Mode().Type() != fs.ModeSymlink {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+	b, err := io.ReadAll(f)
+	if err != nil {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: err}
+	}
+	return string(b), nil
+}
+
+// TarFS reads the tar archive from r and returns an in-memory file system
+// exposing its content, including symbolic links.
+//
+// Parent directories that are not present in the archive are synthesized.
+// Entries which are neither regular files, directories, symbolic links, nor
+// hard links to regular files are ignored.
+func TarFS(r io.Reader) (fs.FS, error) {
+	fsys := tarFS{".": {hdr: tarDirHeader(".")}}
+	tr := tar.NewReader(r)
+
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+
+		name := path.Clean(strings.TrimPrefix(hdr.Name, "/"))
+		if name == "." {
+			continue
+		}
+		if !fs.ValidPath(name) {
+			return nil, &fs.PathError{Op: "tar", Path: hdr.Name, Err: fs.ErrInvalid}
+		}
+
+		file := &tarFile{hdr: hdr}
+		switch hdr.Typeflag {
+		case tar.TypeReg:
+			if file.data, err = io.ReadAll(tr); err != nil {
+				return nil, err
+			}
+		case tar.TypeLink:
+			link := fsys[path.Clean(strings.TrimPrefix(hdr.Linkname, "/"))]
+			if link == nil || link.hdr.Typeflag != tar.TypeReg {
+				continue
+			}
+			h := *link.hdr
+			h.Name = hdr.Name
+			file = &tarFile{hdr: &h, data: link.data}
+		case tar.TypeDir, tar.TypeSymlink:
+		default:
+			continue
+		}
+		fsys[name] = file
+
+		for dir := path.Dir(name); fsys[dir] == nil; dir = path.Dir(dir) {
+			fsys[dir] = &tarFile{hdr: tarDirHeader(dir)}
+		}
+	}
+
+	for name, file := range fsys {
+		if name != "." {
+			parent := fsys[path.Dir(name)]
+			parent.dir = append(parent.dir, fs.FileInfoToDirEntry(file.info(name)))
+		}
+	}
+	for _, file := range fsys {
+		sort.Slice(file.dir, func(i, j int) bool {
+			return file.dir[i].Name() < file.dir[j].Name()
+		})
+	}
+	return fsys, nil
+}
+
+// TarStream writes to w a tar archive of the tree rooted at root in fsys.
+//
+// The root is resolved following symbolic links, and the names of entries in
+// the archive are relative to it. Symbolic links found in the tree are written
+// as symbolic link entries with their raw targets and are not followed, which
+// guarantees that the archive is finite even when links form cycles. Regular
+// files are written with their content and mode, other types of files are
+// ignored like they would be by TarFS.
+func TarStream(w io.Writer, fsys fs.FS, root string) error {
+	tw := tar.NewWriter(w)
+	err := WalkDirFS(fsys, root, func(name string, sub fs.FS, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if name == root {
+			return nil
+		}
+		if root != "." {
+			name = name[len(root)+1:]
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		var link string
+		switch info.Mode().Type() {
+		case 0, fs.ModeDir:
+		case fs.ModeSymlink:
+			if link, err = fslink.ReadLink(sub, d.Name()); err != nil {
+				return err
+			}
+		default:
+			return nil
+		}
+
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = name
+		if info.IsDir() {
+			hdr.Name += "/"
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		f, err := sub.Open(d.Name())
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+func tarDirHeader(name string) *tar.Header {
+	return &tar.Header{Name: name, Typeflag: tar.TypeDir, Mode: 0755}
+}
+
+type tarFS map[string]*tarFile
+
+type tarFile struct {
+	hdr  *tar.Header
+	data []byte
+	dir  []fs.DirEntry
+}
+
+func (f *tarFile) info(name string) fs.FileInfo {
+	return tarFileInfo{f.hdr.FileInfo(), path.Base(name)}
+}
+
+type tarFileInfo struct {
+	fs.FileInfo
+	name string
+}
+
+func (info tarFileInfo) Name() string { return info.name }
+
+func (fsys tarFS) Open(name string) (fs.File, error) {
+	file, err := fsys.lookup("open", name)
+	if err != nil {
+		return nil, err
+	}
+	info := file.info(name)
+	if info.IsDir() {
+		return &tarDir{info: info, dir: file.dir}, nil
+	}
+	return &tarReader{info: info, Reader: bytes.NewReader(file.data)}, nil
+}
+
+func (fsys tarFS) ReadLink(name string) (string, error) {
+	file, err := fsys.lookup("readlink", name)
+	if err != nil {
+		return "", err
+	}
+	if file.hdr.Typeflag != tar.TypeSymlink {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+	return file.hdr.Linkname, nil
+}
+
+func (fsys tarFS) lookup(op, name string) (*tarFile, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	file := fsys[name]
+	if file == nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	return file, nil
+}
+
+type tarReader struct {
+	info fs.FileInfo
+	*bytes.Reader
+}
+
+func (f *tarReader) Stat() (fs.FileInfo, error) { return f.info, nil }
+
+func (f *tarReader) Close() error { return nil }
+
+type tarDir struct {
+	info fs.FileInfo
+	dir  []fs.DirEntry
+}
+
+func (d *tarDir) Stat() (fs.FileInfo, error) { return d.info, nil }
+
+func (d *tarDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.Name(), Err: fs.ErrInvalid}
+}
+
+func (d *tarDir) Close() error { return nil }
+
+func (d *tarDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if n > 0 && len(d.dir) == 0 {
+		return nil, io.EOF
+	}
+	if n <= 0 || n > len(d.dir) {
+		n = len(d.dir)
+	}
+	entries := d.dir[:n:n]
+	d.dir = d.dir[n:]
+	return entries, nil
+}
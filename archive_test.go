@@ -0,0 +1,123 @@
+package fspath_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"io"
+	"io/fs"
+	"testing"
+
+	"github.com/stealthrocket/fspath"
+	"github.com/stealthrocket/fstest"
+)
+
+func TestTarFS(t *testing.T) {
+	buf := new(bytes.Buffer)
+	tw := tar.NewWriter(buf)
+	for _, hdr := range []*tar.Header{
+		{Name: "a/", Typeflag: tar.TypeDir, Mode: 0755},
+		{Name: "a/b", Typeflag: tar.TypeSymlink, Linkname: "../c", Mode: 0777},
+		{Name: "c/d", Typeflag: tar.TypeReg, Mode: 0644, Size: 12},
+	} {
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Size > 0 {
+			io.WriteString(tw, "Hello World!")
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	fsys, err := fspath.TarFS(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testArchiveFS(t, fsys)
+}
+
+func TestZipFS(t *testing.T) {
+	buf := new(bytes.Buffer)
+	zw := zip.NewWriter(buf)
+	for _, file := range []struct {
+		name string
+		mode fs.FileMode
+		data string
+	}{
+		{name: "a/", mode: 0755 | fs.ModeDir},
+		{name: "a/b", mode: 0777 | fs.ModeSymlink, data: "../c"},
+		{name: "c/d", mode: 0644, data: "Hello World!"},
+	} {
+		hdr := &zip.FileHeader{Name: file.name}
+		hdr.SetMode(file.mode)
+		w, err := zw.CreateHeader(hdr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(w, file.data)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testArchiveFS(t, fspath.ZipFS(zr))
+}
+
+func testArchiveFS(t *testing.T, fsys fs.FS) {
+	f, err := fspath.Open(fsys, "a/b/d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	b, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "Hello World!" {
+		t.Errorf("wrong file content: %q", b)
+	}
+
+	link, err := fspath.ReadLink(fsys, "a/b")
+	if err != nil {
+		t.Error(err)
+	} else if link != "../c" {
+		t.Errorf("wrong link: %q", link)
+	}
+
+	if err := fstest.TestFS(fspath.RootFS(fsys), "a", "a/b", "c/d"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTarStream(t *testing.T) {
+	buf := new(bytes.Buffer)
+	err := fspath.TarStream(buf, fstest.MapFS{
+		"a":      &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"a/b":    &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../c")},
+		"a/loop": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("..")},
+		"c/d":    &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+	}, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fsys, err := fspath.TarFS(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testArchiveFS(t, fsys)
+
+	link, err := fspath.ReadLink(fsys, "a/loop")
+	if err != nil {
+		t.Error(err)
+	} else if link != ".." {
+		t.Errorf("wrong link: %q", link)
+	}
+}
@@ -0,0 +1,77 @@
+package fspath
+
+import (
+	"io/fs"
+	"path"
+
+	"github.com/stealthrocket/fslink"
+)
+
+// AuditFS returns a file system wrapping fsys and calling log after each call
+// to Open, Stat, ReadDir, ReadFile, and ReadLink, with the name of the method
+// in lower case, the canonical path of the file, and the error returned by the
+// operation (nil on success).
+//
+// Operations are forwarded to fsys with the names they were called with, the
+// canonical path is obtained by resolving the name separately; when the name
+// cannot be resolved, it is passed to log unchanged. The wrapper can therefore
+// be placed either above or below RootFS without changing the behavior of the
+// file system, at the cost of resolving each path twice. The canonical path of
+// a symbolic link passed to ReadLink resolves the directory of the link only.
+func AuditFS(fsys fs.FS, log func(op, name string, err error)) fs.FS {
+	return auditFS{fsys, log}
+}
+
+type auditFS struct {
+	fs  fs.FS
+	log func(op, name string, err error)
+}
+
+func (fsys auditFS) canonical(name string) string {
+	r, err := defaultOptions.resolve(fsys.fs, name)
+	if err != nil {
+		return name
+	}
+	return r.path()
+}
+
+func (fsys auditFS) Open(name string) (fs.File, error) {
+	f, err := fsys.fs.Open(name)
+	fsys.log("open", fsys.canonical(name), err)
+	return f, err
+}
+
+func (fsys auditFS) Stat(name string) (fs.FileInfo, error) {
+	info, err := fs.Stat(fsys.fs, name)
+	fsys.log("stat", fsys.canonical(name), err)
+	return info, err
+}
+
+func (fsys auditFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	entries, err := fs.ReadDir(fsys.fs, name)
+	fsys.log("readdir", fsys.canonical(name), err)
+	return entries, err
+}
+
+func (fsys auditFS) ReadFile(name string) ([]byte, error) {
+	data, err := fs.ReadFile(fsys.fs, name)
+	fsys.log("readfile", fsys.canonical(name), err)
+	return data, err
+}
+
+func (fsys auditFS) ReadLink(name string) (string, error) {
+	link, err := fslink.ReadLink(fsys.fs, name)
+	canonical := name
+	if dir, base := path.Split(name); dir != "" && fs.ValidPath(name) {
+		canonical = path.Join(fsys.canonical(dir[:len(dir)-1]), base)
+	}
+	fsys.log("readlink", canonical, err)
+	return link, err
+}
+
+var (
+	_ fs.StatFS         = auditFS{}
+	_ fs.ReadDirFS      = auditFS{}
+	_ fs.ReadFileFS     = auditFS{}
+	_ fslink.ReadLinkFS = auditFS{}
+)
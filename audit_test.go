@@ -0,0 +1,55 @@
+package fspath_test
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"reflect"
+	"testing"
+
+	"github.com/stealthrocket/fspath"
+	"github.com/stealthrocket/fstest"
+)
+
+func TestAuditFS(t *testing.T) {
+	var log []string
+	fsys := fspath.AuditFS(fspath.RootFS(fstest.MapFS{
+		"a":   &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"a/b": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../c")},
+		"c/d": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+	}), func(op, name string, err error) {
+		log = append(log, fmt.Sprintf("%s %s %t", op, name, err == nil))
+	})
+
+	if b, err := fs.ReadFile(fsys, "a/b/d"); err != nil {
+		t.Error(err)
+	} else if string(b) != "Hello World!" {
+		t.Errorf("wrong file content: %q", b)
+	}
+	if _, err := fs.Stat(fsys, "a/b"); err != nil {
+		t.Error(err)
+	}
+	if _, err := fs.ReadDir(fsys, "a"); err != nil {
+		t.Error(err)
+	}
+	if _, err := fspath.ReadLink(fsys, "a/b"); err != nil {
+		t.Error(err)
+	}
+	if _, err := fsys.Open("a/b/x"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected fs.ErrNotExist, got %v", err)
+	}
+
+	want := []string{
+		"readfile c/d true",
+		"stat c true",
+		"readdir a true",
+		// fspath.ReadLink resolves the parent directory, probing whether
+		// it is a symbolic link first.
+		"readlink a false",
+		"readlink a/b true",
+		"open c/x false",
+	}
+	if !reflect.DeepEqual(log, want) {
+		t.Errorf("wrong access log:\nwant=%q\ngot= %q", want, log)
+	}
+}
@@ -0,0 +1,272 @@
+package fspath
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+)
+
+// OpenCanonical opens the file at name in fsys, following symbolic links, and
+// returns it together with its canonical path, which is the path of the file
+// relative to the root of fsys after resolving all the links.
+func OpenCanonical(fsys fs.FS, name string) (fs.File, string, error) {
+	r, err := defaultOptions.resolve(fsys, name)
+	if err != nil {
+		return nil, "", err
+	}
+	f, err := r.fsys.Open(r.base)
+	if err != nil {
+		return nil, "", err
+	}
+	return f, r.path(), nil
+}
+
+// ReadFileInfo reads the file at name in fsys, following symbolic links, and
+// returns its content together with information about the file and its
+// canonical path.
+//
+// The path is resolved and the file opened only once, which guarantees that
+// all the returned values describe the same file, and makes it cheaper than
+// calling Stat and ReadFile separately.
+func ReadFileInfo(fsys fs.FS, name string) ([]byte, fs.FileInfo, string, error) {
+	f, canonical, err := OpenCanonical(fsys, name)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	var size int
+	if n := info.Size(); int64(int(n)) == n {
+		size = int(n)
+	}
+	data := make([]byte, 0, size+1)
+	for {
+		if len(data) == cap(data) {
+			data = append(data, 0)[:len(data)]
+		}
+		n, err := f.Read(data[len(data):cap(data)])
+		data = data[:len(data)+n]
+		if err != nil {
+			if err == io.EOF {
+				return data, info, canonical, nil
+			}
+			return nil, nil, "", err
+		}
+	}
+}
+
+// OpenEntry opens the file at name in fsys, following symbolic links, and
+// returns it together with a directory entry describing the resolved file.
+//
+// The entry is built from the information returned by the opened file, so it
+// always describes the same file even if the tree is modified concurrently.
+// Its name is the name of the final component after resolving links, and its
+// type is never fs.ModeSymlink.
+func OpenEntry(fsys fs.FS, name string) (fs.File, fs.DirEntry, error) {
+	r, err := defaultOptions.resolve(fsys, name)
+	if err != nil {
+		return nil, nil, err
+	}
+	f, err := r.fsys.Open(r.base)
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	return f, fs.FileInfoToDirEntry(info), nil
+}
+
+// OpenNamed opens the file at name in fsys, following symbolic links, and
+// returns it wrapped in a fs.File which exposes its canonical path through a
+// ResolvedName method:
+//
+//	f, err := fspath.OpenNamed(fsys, "a/b/d")
+//	...
+//	name := f.(interface{ ResolvedName() string }).ResolvedName()
+//
+// The returned file implements fs.ReadDirFile, io.Seeker, and io.ReaderAt only
+// when the opened file does, so type assertions made on it succeed if and only
+// if they would on the opened file. The opened file can be retrieved by calling
+// Unwrap.
+func OpenNamed(fsys fs.FS, name string) (fs.File, error) {
+	f, canonical, err := OpenCanonical(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	return newNamedFile(f, canonical), nil
+}
+
+type namedFile struct {
+	fs.File
+	name string
+}
+
+func (f *namedFile) ResolvedName() string { return f.name }
+
+func (f *namedFile) Unwrap() fs.File { return f.File }
+
+// dirReader is the method that fs.ReadDirFile adds to fs.File, embedding it
+// next to namedFile does not make the methods of fs.File ambiguous.
+type dirReader interface {
+	ReadDir(n int) ([]fs.DirEntry, error)
+}
+
+// newNamedFile wraps f in a namedFile, combined with each of the optional
+// methods that f implements.
+func newNamedFile(f fs.File, name string) fs.File {
+	n := &namedFile{File: f, name: name}
+	d, isDir := f.(dirReader)
+	s, isSeeker := f.(io.Seeker)
+	r, isReaderAt := f.(io.ReaderAt)
+
+	switch {
+	case isDir && isSeeker && isReaderAt:
+		return struct {
+			*namedFile
+			dirReader
+			io.Seeker
+			io.ReaderAt
+		}{n, d, s, r}
+	case isDir && isSeeker:
+		return struct {
+			*namedFile
+			dirReader
+			io.Seeker
+		}{n, d, s}
+	case isDir && isReaderAt:
+		return struct {
+			*namedFile
+			dirReader
+			io.ReaderAt
+		}{n, d, r}
+	case isSeeker && isReaderAt:
+		return struct {
+			*namedFile
+			io.Seeker
+			io.ReaderAt
+		}{n, s, r}
+	case isDir:
+		return struct {
+			*namedFile
+			dirReader
+		}{n, d}
+	case isSeeker:
+		return struct {
+			*namedFile
+			io.Seeker
+		}{n, s}
+	case isReaderAt:
+		return struct {
+			*namedFile
+			io.ReaderAt
+		}{n, r}
+	default:
+		return n
+	}
+}
+
+// ReadFileInto reads the file at name in fsys, following symbolic links, into
+// buf and returns the number of bytes read. This avoids allocating memory when
+// repeatedly reading small files, buffers can be reused across calls.
+//
+// If the file is larger than buf, the function returns an error wrapping
+// io.ErrShortBuffer, along with the number of bytes read into buf.
+func ReadFileInto(fsys fs.FS, name string, buf []byte) (int, error) {
+	f, err := Open(fsys, name)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	n, err := io.ReadFull(f, buf)
+	switch err {
+	case nil:
+	case io.EOF, io.ErrUnexpectedEOF:
+		return n, nil
+	default:
+		return n, err
+	}
+	// The buffer was filled, the file is larger unless there is nothing left
+	// to read.
+	var b [1]byte
+	switch _, err := io.ReadFull(f, b[:]); err {
+	case io.EOF:
+		return n, nil
+	case nil:
+		return n, &fs.PathError{Op: "read", Path: name, Err: io.ErrShortBuffer}
+	default:
+		return n, err
+	}
+}
+
+// SameFileFS is the interface implemented by file systems which can tell
+// whether two files are the same, similarly to os.SameFile.
+type SameFileFS interface {
+	fs.FS
+	SameFile(fi1, fi2 fs.FileInfo) bool
+}
+
+// SameFile reports whether the paths a and b resolve to the same file in fsys,
+// following symbolic links. Both files must exist.
+//
+// If fsys implements SameFileFS, its SameFile method is used to compare the
+// files, which also detects hard links. Otherwise, the canonical paths that a
+// and b resolve to are compared.
+func SameFile(fsys fs.FS, a, b string) (bool, error) {
+	ra, err := defaultOptions.resolve(fsys, a)
+	if err != nil {
+		return false, err
+	}
+	rb, err := defaultOptions.resolve(fsys, b)
+	if err != nil {
+		return false, err
+	}
+	fa, err := fs.Stat(ra.fsys, ra.base)
+	if err != nil {
+		return false, err
+	}
+	fb, err := fs.Stat(rb.fsys, rb.base)
+	if err != nil {
+		return false, err
+	}
+	if f, ok := fsys.(SameFileFS); ok {
+		return f.SameFile(fa, fb), nil
+	}
+	return ra.path() == rb.path(), nil
+}
+
+// Revalidate resolves name in fsys again, following symbolic links, and reports
+// whether it still refers to the file described by previous. This is useful to
+// detect that a link was retargeted or a file replaced when caching data about
+// files of a mutable file system.
+//
+// The name, size, modification time, and mode of the files are compared, as
+// well as their identity if fsys implements SameFileFS. The function returns
+// false with no error if the file does not exist anymore.
+func Revalidate(fsys fs.FS, name string, previous fs.FileInfo) (bool, error) {
+	info, err := Stat(fsys, name)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	if info.Name() != previous.Name() ||
+		info.Size() != previous.Size() ||
+		!info.ModTime().Equal(previous.ModTime()) ||
+		info.Mode() != previous.Mode() {
+		return false, nil
+	}
+	if f, ok := fsys.(SameFileFS); ok {
+		return f.SameFile(previous, info), nil
+	}
+	return true, nil
+}
@@ -0,0 +1,337 @@
+package fspath_test
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stealthrocket/fspath"
+	"github.com/stealthrocket/fstest"
+)
+
+func TestOpenCanonical(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a":   &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"a/b": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../../c")},
+		"c/d": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+	}
+
+	for _, test := range [...]struct {
+		name      string
+		canonical string
+	}{
+		{name: "a/b/d", canonical: "c/d"},
+		{name: "c/d", canonical: "c/d"},
+		{name: "a/b", canonical: "c"},
+		{name: ".", canonical: "."},
+	} {
+		f, canonical, err := fspath.OpenCanonical(fsys, test.name)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		f.Close()
+		if canonical != test.canonical {
+			t.Errorf("%s: wrong canonical path: want=%q got=%q", test.name, test.canonical, canonical)
+		}
+	}
+
+	f, _, err := fspath.OpenCanonical(fsys, "a/b/d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	b, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "Hello World!" {
+		t.Errorf("wrong file content: %q", b)
+	}
+}
+
+func TestReadFileInfo(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a":   &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"a/b": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../../c")},
+		"c/d": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+	}
+
+	data, info, canonical, err := fspath.ReadFileInfo(fsys, "a/b/d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "Hello World!" {
+		t.Errorf("wrong file content: %q", data)
+	}
+	if info.Name() != "d" || info.Size() != int64(len(data)) || info.Mode() != 0644 {
+		t.Errorf("wrong file info: name=%q size=%d mode=%v", info.Name(), info.Size(), info.Mode())
+	}
+	if canonical != "c/d" {
+		t.Errorf("wrong canonical path: %q", canonical)
+	}
+}
+
+func TestOpenEntry(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a":   &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"a/b": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../../c")},
+		"c/d": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+	}
+
+	f, entry, err := fspath.OpenEntry(fsys, "a/b/d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if name := entry.Name(); name != "d" {
+		t.Errorf("wrong entry name: want=%q got=%q", "d", name)
+	}
+	if typ := entry.Type(); typ != 0 {
+		t.Errorf("wrong entry type: want=%v got=%v", fs.FileMode(0), typ)
+	}
+	b, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "Hello World!" {
+		t.Errorf("wrong file content: %q", b)
+	}
+
+	d, entry, err := fspath.OpenEntry(fsys, "a/b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Close()
+	if !entry.IsDir() {
+		t.Errorf("expected a/b to resolve to a directory")
+	}
+}
+
+func TestOpenNamed(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a":   &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"a/b": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../../c")},
+		"c/d": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+	}
+
+	f, err := fspath.OpenNamed(fsys, "a/b/d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	named, ok := f.(interface{ ResolvedName() string })
+	if !ok {
+		t.Fatal("file does not implement ResolvedName")
+	}
+	if name := named.ResolvedName(); name != "c/d" {
+		t.Errorf("wrong resolved name: want=%q got=%q", "c/d", name)
+	}
+	b, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "Hello World!" {
+		t.Errorf("wrong file content: %q", b)
+	}
+
+	d, err := fspath.OpenNamed(fsys, "a/b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	entries, err := d.(fs.ReadDirFile).ReadDir(-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "d" {
+		t.Errorf("wrong directory entries: %v", entries)
+	}
+}
+
+func TestOpenNamedMethods(t *testing.T) {
+	mapFS := fstest.MapFS{
+		"a":   &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"a/b": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../../c")},
+		"c/d": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+	}
+	osFS := fspath.DirFS(makeDirTree(t))
+
+	// The wrapper implements the optional methods of the opened file, and
+	// only those.
+	for _, fsys := range []fs.FS{mapFS, noSeekFS{mapFS}, osFS} {
+		for _, name := range []string{"a/b", "a/b/d"} {
+			f, err := fspath.OpenNamed(fsys, name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			file := f.(interface{ Unwrap() fs.File }).Unwrap()
+
+			for _, check := range []struct {
+				iface string
+				is    func(fs.File) bool
+			}{
+				{"fs.ReadDirFile", func(f fs.File) bool { _, ok := f.(fs.ReadDirFile); return ok }},
+				{"io.Seeker", func(f fs.File) bool { _, ok := f.(io.Seeker); return ok }},
+				{"io.ReaderAt", func(f fs.File) bool { _, ok := f.(io.ReaderAt); return ok }},
+			} {
+				if want, got := check.is(file), check.is(f); want != got {
+					t.Errorf("%T: %s: %s: want=%t got=%t", fsys, name, check.iface, want, got)
+				}
+			}
+			if _, ok := f.(interface{ ResolvedName() string }); !ok {
+				t.Errorf("%T: %s: file does not implement ResolvedName", fsys, name)
+			}
+		}
+	}
+}
+
+func TestReadFileInto(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a":   &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"a/b": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../../c")},
+		"c/d": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+	}
+
+	for _, size := range []int{12, 64} {
+		buf := make([]byte, size)
+		n, err := fspath.ReadFileInto(fsys, "a/b/d", buf)
+		if err != nil {
+			t.Errorf("size=%d: %v", size, err)
+			continue
+		}
+		if string(buf[:n]) != "Hello World!" {
+			t.Errorf("size=%d: wrong file content: %q", size, buf[:n])
+		}
+	}
+}
+
+func TestReadFileIntoShortBuffer(t *testing.T) {
+	fsys := fstest.MapFS{
+		"c/d": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+	}
+
+	buf := make([]byte, 5)
+	n, err := fspath.ReadFileInto(fsys, "c/d", buf)
+	if !errors.Is(err, io.ErrShortBuffer) {
+		t.Errorf("expected io.ErrShortBuffer, got %v", err)
+	}
+	if string(buf[:n]) != "Hello" {
+		t.Errorf("wrong file content: %q", buf[:n])
+	}
+}
+
+func TestSameFile(t *testing.T) {
+	mapFS := fstest.MapFS{
+		"a":   &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"a/b": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../c")},
+		"c/d": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+		"c/e": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+	}
+
+	dir := makeDirTree(t)
+	if err := os.WriteFile(filepath.Join(dir, "c", "e"), []byte("Hello World!"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Link(filepath.Join(dir, "c", "d"), filepath.Join(dir, "c", "f")); err != nil {
+		t.Fatal(err)
+	}
+	dirFS := fspath.DirFS(dir)
+
+	for _, test := range []struct {
+		name string
+		fsys fs.FS
+		a, b string
+		same bool
+	}{
+		{name: "map/link", fsys: mapFS, a: "a/b/d", b: "c/d", same: true},
+		{name: "map/other", fsys: mapFS, a: "a/b/d", b: "c/e", same: false},
+		{name: "dir/link", fsys: dirFS, a: "a/b/d", b: "c/d", same: true},
+		{name: "dir/other", fsys: dirFS, a: "a/b/d", b: "c/e", same: false},
+		{name: "dir/hardlink", fsys: dirFS, a: "a/b/d", b: "c/f", same: true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			same, err := fspath.SameFile(test.fsys, test.a, test.b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if same != test.same {
+				t.Errorf("wrong result: want=%t got=%t", test.same, same)
+			}
+		})
+	}
+
+	if _, err := fspath.SameFile(mapFS, "a/b/d", "c/x"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected fs.ErrNotExist, got %v", err)
+	}
+}
+
+func TestRevalidate(t *testing.T) {
+	dir := makeDirTree(t)
+	if err := os.WriteFile(filepath.Join(dir, "c", "e"), []byte("Goodbye!"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("c/d", filepath.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+	mapFS := fstest.MapFS{
+		"c/d":  &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+		"c/e":  &fstest.MapFile{Mode: 0644, Data: []byte("Goodbye!")},
+		"link": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("c/d")},
+	}
+
+	for _, test := range []struct {
+		name     string
+		fsys     fs.FS
+		retarget func(string) error
+	}{
+		{name: "map", fsys: mapFS, retarget: func(target string) error {
+			mapFS["link"] = &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte(target)}
+			return nil
+		}},
+		{name: "dir", fsys: fspath.DirFS(dir), retarget: func(target string) error {
+			tmp := filepath.Join(dir, "link.tmp")
+			if err := os.Symlink(target, tmp); err != nil {
+				return err
+			}
+			return os.Rename(tmp, filepath.Join(dir, "link"))
+		}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			info, err := fspath.Stat(test.fsys, "link")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok, err := fspath.Revalidate(test.fsys, "link", info); err != nil {
+				t.Fatal(err)
+			} else if !ok {
+				t.Error("unchanged path was reported as invalid")
+			}
+
+			if err := test.retarget("c/e"); err != nil {
+				t.Fatal(err)
+			}
+			if ok, err := fspath.Revalidate(test.fsys, "link", info); err != nil {
+				t.Fatal(err)
+			} else if ok {
+				t.Error("retargeted link was reported as valid")
+			}
+
+			if err := test.retarget("c/missing"); err != nil {
+				t.Fatal(err)
+			}
+			if ok, err := fspath.Revalidate(test.fsys, "link", info); err != nil {
+				t.Fatal(err)
+			} else if ok {
+				t.Error("dangling link was reported as valid")
+			}
+		})
+	}
+}
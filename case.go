@@ -0,0 +1,38 @@
+package fspath
+
+import (
+	"io/fs"
+	"strings"
+)
+
+// CaseConflicts returns the groups of entries in dir whose names differ only by
+// case, which would collide when copied to a case-insensitive file system. The
+// dir argument is resolved following symbolic links.
+//
+// Names within each group, and the groups themselves, are sorted in the order
+// of the directory entries, which is lexical for file systems following the
+// fs.ReadDir conventions. A nil slice is returned when there are no conflicts.
+func CaseConflicts(fsys fs.FS, dir string) ([][]string, error) {
+	entries, err := ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	names := make(map[string][]string, len(entries))
+	for _, entry := range entries {
+		key := strings.ToLower(entry.Name())
+		if names[key] == nil {
+			keys = append(keys, key)
+		}
+		names[key] = append(names[key], entry.Name())
+	}
+
+	var conflicts [][]string
+	for _, key := range keys {
+		if group := names[key]; len(group) > 1 {
+			conflicts = append(conflicts, group)
+		}
+	}
+	return conflicts, nil
+}
@@ -0,0 +1,43 @@
+package fspath_test
+
+import (
+	"io/fs"
+	"reflect"
+	"testing"
+
+	"github.com/stealthrocket/fspath"
+	"github.com/stealthrocket/fstest"
+)
+
+func TestCaseConflicts(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a":          &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"a/b":        &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../c")},
+		"c/README":   &fstest.MapFile{Mode: 0644},
+		"c/ReadMe":   &fstest.MapFile{Mode: 0644},
+		"c/readme":   &fstest.MapFile{Mode: 0644},
+		"c/Makefile": &fstest.MapFile{Mode: 0644},
+		"c/src/x":    &fstest.MapFile{Mode: 0644},
+		"c/Src":      &fstest.MapFile{Mode: 0644},
+	}
+
+	conflicts, err := fspath.CaseConflicts(fsys, "a/b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"README", "ReadMe", "readme"},
+		{"Src", "src"},
+	}
+	if !reflect.DeepEqual(conflicts, want) {
+		t.Errorf("mismatch: want=%q got=%q", want, conflicts)
+	}
+
+	conflicts, err = fspath.CaseConflicts(fsys, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conflicts != nil {
+		t.Errorf("unexpected conflicts: %q", conflicts)
+	}
+}
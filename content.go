@@ -0,0 +1,68 @@
+package fspath
+
+import (
+	"bytes"
+	"hash"
+	"io"
+	"io/fs"
+	"net/http"
+)
+
+// OpenContentType opens the file at name in fsys, following symbolic links, and
+// returns it together with its content type, detected by http.DetectContentType
+// from the first 512 bytes of the file.
+//
+// Reading from the returned file starts at the beginning of its content. If the
+// file implements io.Seeker, it is rewound and returned as-is, otherwise it is
+// wrapped to replay the bytes consumed by the detection; the opened file can be
+// retrieved by calling Unwrap on the wrapper.
+func OpenContentType(fsys fs.FS, name string) (fs.File, string, error) {
+	f, err := Open(fsys, name)
+	if err != nil {
+		return nil, "", err
+	}
+
+	buf := make([]byte, 512)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		f.Close()
+		return nil, "", err
+	}
+	buf = buf[:n]
+	contentType := http.DetectContentType(buf)
+
+	if s, ok := f.(io.Seeker); ok {
+		if _, err := s.Seek(0, io.SeekStart); err != nil {
+			f.Close()
+			return nil, "", err
+		}
+		return f, contentType, nil
+	}
+	return &sniffedFile{File: f, r: io.MultiReader(bytes.NewReader(buf), f)}, contentType, nil
+}
+
+type sniffedFile struct {
+	fs.File
+	r io.Reader
+}
+
+func (f *sniffedFile) Read(b []byte) (int, error) { return f.r.Read(b) }
+
+func (f *sniffedFile) Unwrap() fs.File { return f.File }
+
+// Checksum opens the file at name in fsys, following symbolic links, and returns
+// the digest of its content computed by h. The content is streamed through h
+// rather than loaded in memory. The hash is reset before writing the content.
+func Checksum(fsys fs.FS, name string, h hash.Hash) ([]byte, error) {
+	f, err := Open(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h.Reset()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
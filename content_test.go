@@ -0,0 +1,97 @@
+package fspath_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"testing"
+
+	"github.com/stealthrocket/fslink"
+	"github.com/stealthrocket/fspath"
+	"github.com/stealthrocket/fstest"
+)
+
+// noSeekFS hides the io.Seeker implementation of the files it opens, but keeps
+// exposing the symbolic links of the file system.
+type noSeekFS struct{ fs.FS }
+
+func (fsys noSeekFS) ReadLink(name string) (string, error) {
+	return fslink.ReadLink(fsys.FS, name)
+}
+
+func (fsys noSeekFS) Open(name string) (fs.File, error) {
+	f, err := fsys.FS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return struct{ fs.File }{f}, nil
+}
+
+func TestOpenContentType(t *testing.T) {
+	mapFS := fstest.MapFS{
+		"a":         &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"a/b":       &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../c")},
+		"c/d":       &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+		"c/e.html":  &fstest.MapFile{Mode: 0644, Data: []byte("<!DOCTYPE html><p>Hello World!</p>")},
+		"c/f/empty": &fstest.MapFile{Mode: 0644},
+		// The link is clamped to the root by the resolution.
+		"a/up": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../../c/d")},
+	}
+
+	for _, fsys := range []fs.FS{mapFS, noSeekFS{mapFS}} {
+		for _, test := range [...]struct {
+			name        string
+			data        string
+			contentType string
+		}{
+			{name: "a/b/d", data: "Hello World!", contentType: "text/plain; charset=utf-8"},
+			{name: "a/b/e.html", data: "<!DOCTYPE html><p>Hello World!</p>", contentType: "text/html; charset=utf-8"},
+			{name: "c/f/empty", data: "", contentType: "text/plain; charset=utf-8"},
+			{name: "a/up", data: "Hello World!", contentType: "text/plain; charset=utf-8"},
+		} {
+			f, contentType, err := fspath.OpenContentType(fsys, test.name)
+			if err != nil {
+				t.Error(err)
+				continue
+			}
+			b, err := io.ReadAll(f)
+			f.Close()
+			if err != nil {
+				t.Error(err)
+				continue
+			}
+			if contentType != test.contentType {
+				t.Errorf("%s: wrong content type: want=%q got=%q", test.name, test.contentType, contentType)
+			}
+			if string(b) != test.data {
+				t.Errorf("%s: wrong file content: want=%q got=%q", test.name, test.data, b)
+			}
+		}
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a":   &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"a/b": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../c")},
+		"c/d": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+	}
+
+	h := sha256.New()
+	h.Write([]byte("garbage"))
+
+	sum, err := fspath.Checksum(fsys, "a/b/d", h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = "7f83b1657ff1fc53b92dc18148a1d65dfc2d4b1fa3d677284addd200126d9069"
+	if got := hex.EncodeToString(sum); got != want {
+		t.Errorf("wrong checksum: want=%s got=%s", want, got)
+	}
+
+	if _, err := fspath.Checksum(fsys, "a/b/x", h); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected fs.ErrNotExist, got %v", err)
+	}
+}
@@ -0,0 +1,232 @@
+package fspath
+
+import (
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/stealthrocket/fslink"
+)
+
+// WriteFS is the interface implemented by file systems which support creating
+// directories and writing files.
+type WriteFS interface {
+	fs.FS
+	MkdirAll(name string, perm fs.FileMode) error
+	WriteFile(name string, data []byte, perm fs.FileMode) error
+}
+
+// SymlinkFS is the interface implemented by file systems which support creating
+// symbolic links.
+type SymlinkFS interface {
+	fs.FS
+	Symlink(oldname, newname string) error
+}
+
+// WithPreserveLinks configures CopyTree to recreate the symbolic links found in
+// the source tree instead of copying the files that they point to.
+//
+// The targets of links are rewritten as the shortest relative path which
+// resolves to the same file in the destination, absolute targets are taken to
+// be relative to the root of src. Links pointing outside of the
+// copied tree cause CopyTree to fail with ErrEscape, unless
+// WithDereferenceEscapingLinks is also passed.
+func WithPreserveLinks() Option {
+	return func(o *options) { o.preserveLinks = true }
+}
+
+// WithDereferenceEscapingLinks configures CopyTree to copy the files pointed to
+// by symbolic links escaping the copied tree when links are preserved.
+func WithDereferenceEscapingLinks() Option {
+	return func(o *options) { o.dereferenceEscapingLinks = true }
+}
+
+// CopyTree copies the tree rooted at root in src to the root of dst, which must
+// implement WriteFS, and SymlinkFS when links are preserved, otherwise
+// ErrUnsupported is returned.
+//
+// The root is resolved following symbolic links with the given options. By
+// default the symbolic links found in the tree are dereferenced, directories
+// that they point to are copied recursively, and links pointing to one of the
+// directories being copied fail with ErrLoop. WithPreserveLinks may be used to
+// recreate the links in dst instead.
+//
+// Files which are neither directories, regular files, nor symbolic links are
+// ignored.
+func CopyTree(dst, src fs.FS, root string, opts ...Option) error {
+	w, ok := dst.(WriteFS)
+	if !ok {
+		return &fs.PathError{Op: "copy", Path: root, Err: ErrUnsupported}
+	}
+	c := &copier{dst: w, src: src, opts: newOptions(opts)}
+	if c.opts.preserveLinks {
+		if c.links, ok = dst.(SymlinkFS); !ok {
+			return &fs.PathError{Op: "copy", Path: root, Err: ErrUnsupported}
+		}
+	}
+	r, err := c.opts.resolve(src, root)
+	if err != nil {
+		return err
+	}
+	return c.copyTree(r.path(), ".")
+}
+
+// Flatten copies the tree rooted at root in src to the root of dst, replacing
+// every symbolic link with a copy of the file or directory that it resolves
+// to, which produces a self-contained tree without links. The dst file system
+// must implement WriteFS, otherwise ErrUnsupported is returned.
+//
+// Links pointing to one of the directories containing them would produce an
+// infinite tree, Flatten fails with ErrLoop when finding one of those.
+func Flatten(dst, src fs.FS, root string) error {
+	return CopyTree(dst, src, root)
+}
+
+type copier struct {
+	dst    WriteFS
+	links  SymlinkFS
+	src    fs.FS
+	opts   *options
+	frames []copyFrame
+}
+
+// copyFrame maps a directory of the source file system to the directory of the
+// destination that it is being copied to.
+type copyFrame struct {
+	src string
+	dst string
+}
+
+func (c *copier) copyTree(srcDir, dstDir string) error {
+	c.frames = append(c.frames, copyFrame{src: srcDir, dst: dstDir})
+	defer func() { c.frames = c.frames[:len(c.frames)-1] }()
+
+	return WalkDirFS(c.src, srcDir, func(name string, sub fs.FS, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		target := path.Join(dstDir, relPath(name, srcDir))
+
+		switch d.Type() {
+		case fs.ModeDir:
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			return c.dst.MkdirAll(target, info.Mode().Perm())
+		case 0:
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			data, err := fs.ReadFile(sub, d.Name())
+			if err != nil {
+				return err
+			}
+			return c.dst.WriteFile(target, data, info.Mode().Perm())
+		case fs.ModeSymlink:
+			// The link is read from the source rather than from the view of
+			// its directory, which rejects absolute targets.
+			var link string
+			if f, ok := c.src.(fslink.ReadLinkFS); ok {
+				link, err = f.ReadLink(name)
+			} else {
+				link, err = fslink.ReadLink(sub, d.Name())
+			}
+			if err != nil {
+				return err
+			}
+			return c.copyLink(name, link, target)
+		default:
+			return nil
+		}
+	})
+}
+
+func (c *copier) copyLink(name, link, target string) error {
+	if c.opts.preserveLinks {
+		// The link is resolved lexically, leading ".." segments pointing above
+		// the root are clamped like they are when resolving paths, and
+		// absolute targets are relative to the root of the source.
+		to := path.Join(path.Dir(name), link)
+		if path.IsAbs(link) {
+			to = path.Clean(link[1:])
+		}
+		if c.opts.windowsLinkTargets {
+			if l, rooted := windowsLinkTarget(link); rooted {
+				to = path.Clean(l)
+			} else {
+				to = path.Join(path.Dir(name), l)
+			}
+		}
+		for to == ".." || strings.HasPrefix(to, "../") {
+			to = strings.TrimPrefix(strings.TrimPrefix(to, ".."), "/")
+		}
+		if to == "" {
+			to = "."
+		}
+
+		for i := len(c.frames) - 1; i >= 0; i-- {
+			frame := c.frames[i]
+			if !hasPathPrefix(to, frame.src) {
+				continue
+			}
+			rewritten, err := LinkTarget(target, path.Join(frame.dst, relPath(to, frame.src)))
+			if err != nil {
+				return err
+			}
+			return c.links.Symlink(rewritten, target)
+		}
+
+		if !c.opts.dereferenceEscapingLinks {
+			return &fs.PathError{Op: "copy", Path: name, Err: ErrEscape}
+		}
+	}
+
+	r, err := c.opts.resolve(c.src, name)
+	if err != nil {
+		return err
+	}
+	info, err := fs.Stat(r.fsys, r.base)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case info.IsDir():
+		dir := r.path()
+		// Copying a directory which contains the link would never terminate.
+		for _, frame := range append(c.frames, copyFrame{src: path.Dir(name)}) {
+			if hasPathPrefix(frame.src, dir) {
+				return &fs.PathError{Op: "copy", Path: name, Err: ErrLoop}
+			}
+		}
+		return c.copyTree(dir, target)
+	case info.Mode().IsRegular():
+		data, err := fs.ReadFile(r.fsys, r.base)
+		if err != nil {
+			return err
+		}
+		return c.dst.WriteFile(target, data, info.Mode().Perm())
+	default:
+		return nil
+	}
+}
+
+// hasPathPrefix reports whether name is dir or one of the files it contains.
+func hasPathPrefix(name, dir string) bool {
+	return dir == "." || name == dir || strings.HasPrefix(name, dir+"/")
+}
+
+// relPath returns the path of name relative to dir, which must be one of its
+// parent directories.
+func relPath(name, dir string) string {
+	switch {
+	case name == dir:
+		return "."
+	case dir == ".":
+		return name
+	default:
+		return name[len(dir)+1:]
+	}
+}
@@ -0,0 +1,222 @@
+package fspath_test
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+
+	"github.com/stealthrocket/fspath"
+	"github.com/stealthrocket/fstest"
+)
+
+func TestCopyTree(t *testing.T) {
+	src := fspath.DirFS(makeDirTree(t))
+	dir := t.TempDir()
+	dst := fspath.DirFS(dir)
+
+	if err := fspath.CopyTree(dst, src, ".", fspath.WithPreserveLinks()); err != nil {
+		t.Fatal(err)
+	}
+
+	// The link pointed above the root of the source, it was rewritten so it
+	// remains valid in the destination directory.
+	link, err := os.Readlink(filepath.Join(dir, "a", "b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if link != "../c" {
+		t.Errorf("wrong link: %q", link)
+	}
+
+	b, err := fspath.ReadFile(dst, "a/b/d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "Hello World!" {
+		t.Errorf("wrong file content: %q", b)
+	}
+}
+
+func TestCopyTreeDereference(t *testing.T) {
+	src := fspath.DirFS(makeDirTree(t))
+	dir := t.TempDir()
+	dst := fspath.DirFS(dir)
+
+	if err := fspath.CopyTree(dst, src, "a"); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Lstat(filepath.Join(dir, "b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.IsDir() {
+		t.Errorf("expected the link to be copied as a directory: %v", info.Mode())
+	}
+	b, err := os.ReadFile(filepath.Join(dir, "b", "d"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "Hello World!" {
+		t.Errorf("wrong file content: %q", b)
+	}
+}
+
+func TestCopyTreeEscape(t *testing.T) {
+	src := fspath.DirFS(makeDirTree(t))
+
+	err := fspath.CopyTree(fspath.DirFS(t.TempDir()), src, "a", fspath.WithPreserveLinks())
+	if !errors.Is(err, fspath.ErrEscape) {
+		t.Errorf("expected ErrEscape, got %v", err)
+	}
+
+	dir := t.TempDir()
+	err = fspath.CopyTree(fspath.DirFS(dir), src, "a",
+		fspath.WithPreserveLinks(),
+		fspath.WithDereferenceEscapingLinks(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.ReadFile(filepath.Join(dir, "b", "d")); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCopyTreeLoop(t *testing.T) {
+	src := fstest.MapFS{
+		"a/b": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("..")},
+	}
+	err := fspath.CopyTree(fspath.DirFS(t.TempDir()), src, ".")
+	if !errors.Is(err, fspath.ErrLoop) {
+		t.Errorf("expected ErrLoop, got %v", err)
+	}
+}
+
+func TestCopyTreeWindowsLinkTargets(t *testing.T) {
+	src := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(src, "a"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(src, "data"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "data", "file"), []byte("Hello World!"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(`C:\data\file`, filepath.Join(src, "a", "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	err := fspath.CopyTree(fspath.DirFS(dir), fspath.DirFS(src), ".",
+		fspath.WithPreserveLinks(),
+		fspath.WithWindowsLinkTargets(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	link, err := os.Readlink(filepath.Join(dir, "a", "link"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if link != "../data/file" {
+		t.Errorf("wrong link: %q", link)
+	}
+}
+
+func TestCopyTreeAbsoluteLinkTargets(t *testing.T) {
+	src := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(src, "a"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(src, "data"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "data", "file"), []byte("Hello World!"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("/data/../data/file", filepath.Join(src, "a", "link")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("/../a", filepath.Join(src, "data", "up")); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	err := fspath.CopyTree(fspath.DirFS(dir), fspath.DirFS(src), ".", fspath.WithPreserveLinks())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, want := range map[string]string{
+		"a/link":  "../data/file",
+		"data/up": "../a",
+	} {
+		link, err := os.Readlink(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if link != want {
+			t.Errorf("%s: wrong link: want=%q got=%q", name, want, link)
+		}
+	}
+
+	err = fspath.CopyTree(fspath.DirFS(t.TempDir()), fspath.DirFS(src), "a", fspath.WithPreserveLinks())
+	if !errors.Is(err, fspath.ErrEscape) {
+		t.Errorf("expected ErrEscape, got %v", err)
+	}
+}
+
+func TestFlatten(t *testing.T) {
+	src := fstest.MapFS{
+		"shared/config": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+		"app/config":    &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../shared/config")},
+		"app/lib":       &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../shared")},
+	}
+	dir := t.TempDir()
+
+	if err := fspath.Flatten(fspath.DirFS(dir), src, "."); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"shared/config", "app/config", "app/lib/config"} {
+		info, err := os.Lstat(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !info.Mode().IsRegular() {
+			t.Errorf("%s: expected a regular file: %v", name, info.Mode())
+		}
+		if info, err = os.Lstat(filepath.Join(dir, filepath.FromSlash(path.Dir(name)))); err != nil {
+			t.Fatal(err)
+		}
+		if !info.IsDir() {
+			t.Errorf("%s: expected a directory: %v", path.Dir(name), info.Mode())
+		}
+	}
+
+	// The copies are independent, changing one does not affect the other.
+	if err := os.WriteFile(filepath.Join(dir, "app", "config"), []byte("changed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(filepath.Join(dir, "shared", "config"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "Hello World!" {
+		t.Errorf("wrong file content: %q", b)
+	}
+}
+
+func TestFlattenLoop(t *testing.T) {
+	src := fstest.MapFS{
+		"a/b/c": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../../a")},
+	}
+	if err := fspath.Flatten(fspath.DirFS(t.TempDir()), src, "."); !errors.Is(err, fspath.ErrLoop) {
+		t.Errorf("expected ErrLoop, got %v", err)
+	}
+}
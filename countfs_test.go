@@ -0,0 +1,68 @@
+package fspath_test
+
+import (
+	"io/fs"
+	"sync"
+
+	"github.com/stealthrocket/fstest"
+)
+
+// countFS records the names passed to the Open, ReadDir, and ReadLink methods
+// of the file system it wraps. It does not implement fs.SubFS so that the views
+// of sub-directories keep calling through it with names relative to its root,
+// and it does not implement Lstat either. It is safe to use from multiple
+// goroutines.
+type countFS struct {
+	fsys  fstest.MapFS
+	mutex sync.Mutex
+	log   map[string][]string
+}
+
+func (fsys *countFS) record(op, name string) {
+	fsys.mutex.Lock()
+	defer fsys.mutex.Unlock()
+	if fsys.log == nil {
+		fsys.log = make(map[string][]string)
+	}
+	fsys.log[op] = append(fsys.log[op], name)
+}
+
+// calls returns the names passed to op ("open", "readdir", or "readlink") since
+// the last reset, in order.
+func (fsys *countFS) calls(op string) []string {
+	fsys.mutex.Lock()
+	defer fsys.mutex.Unlock()
+	return append([]string(nil), fsys.log[op]...)
+}
+
+func (fsys *countFS) count(op string) int { return len(fsys.calls(op)) }
+
+// counts returns the number of calls made to op for each name.
+func (fsys *countFS) counts(op string) map[string]int {
+	counts := make(map[string]int)
+	for _, name := range fsys.calls(op) {
+		counts[name]++
+	}
+	return counts
+}
+
+func (fsys *countFS) reset() {
+	fsys.mutex.Lock()
+	defer fsys.mutex.Unlock()
+	fsys.log = nil
+}
+
+func (fsys *countFS) Open(name string) (fs.File, error) {
+	fsys.record("open", name)
+	return fsys.fsys.Open(name)
+}
+
+func (fsys *countFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	fsys.record("readdir", name)
+	return fsys.fsys.ReadDir(name)
+}
+
+func (fsys *countFS) ReadLink(name string) (string, error) {
+	fsys.record("readlink", name)
+	return fsys.fsys.ReadLink(name)
+}
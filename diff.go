@@ -0,0 +1,82 @@
+package fspath
+
+import (
+	"errors"
+	"io/fs"
+	"sort"
+
+	"github.com/stealthrocket/fslink"
+)
+
+// LinkChange describes a symbolic link which differs between two snapshots of
+// a file system.
+//
+// OldTarget is empty when the link was added, and NewTarget is empty when the
+// link was removed.
+type LinkChange struct {
+	Path      string
+	OldTarget string
+	NewTarget string
+}
+
+// DiffLinks walks the tree rooted at root in both old and new, and returns the
+// symbolic links that were added, removed, or retargeted between the two, in
+// lexical order of their paths.
+//
+// The root is resolved following symbolic links in each file system, while the
+// links found in the tree are compared but not followed.
+//
+// A root missing from one of the snapshots is treated as an empty tree, every
+// link found under the root of the other snapshot is then reported as added or
+// removed.
+func DiffLinks(old, new fs.FS, root string) ([]LinkChange, error) {
+	oldLinks, err := walkLinks(old, root)
+	if err != nil {
+		return nil, err
+	}
+	newLinks, err := walkLinks(new, root)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []LinkChange
+	for name, oldTarget := range oldLinks {
+		if newTarget := newLinks[name]; newTarget != oldTarget {
+			changes = append(changes, LinkChange{Path: name, OldTarget: oldTarget, NewTarget: newTarget})
+		}
+	}
+	for name, newTarget := range newLinks {
+		if _, ok := oldLinks[name]; !ok {
+			changes = append(changes, LinkChange{Path: name, NewTarget: newTarget})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes, nil
+}
+
+func walkLinks(fsys fs.FS, root string) (map[string]string, error) {
+	links := make(map[string]string)
+	visited := false
+	err := WalkDirFS(fsys, root, func(name string, sub fs.FS, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		visited = true
+		if d.Type() == fs.ModeSymlink {
+			link, err := fslink.ReadLink(sub, d.Name())
+			if err != nil {
+				return err
+			}
+			links[name] = link
+		}
+		return nil
+	})
+	// The root could not be found, either when resolving it or when reading
+	// its metadata, no entries were visited.
+	if !visited && errors.Is(err, fs.ErrNotExist) {
+		err = nil
+	}
+	return links, err
+}
@@ -0,0 +1,97 @@
+package fspath_test
+
+import (
+	"io/fs"
+	"reflect"
+	"testing"
+
+	"github.com/stealthrocket/fspath"
+	"github.com/stealthrocket/fstest"
+)
+
+func TestDiffLinks(t *testing.T) {
+	old := fstest.MapFS{
+		"a":   &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"a/b": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../c")},
+		"a/e": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("b/d")},
+		"a/f": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("b")},
+		"c/d": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+	}
+	new := fstest.MapFS{
+		"a":   &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"a/b": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../x")},
+		"a/f": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("b")},
+		"a/g": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("f")},
+		"x/d": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+	}
+
+	changes, err := fspath.DiffLinks(old, new, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []fspath.LinkChange{
+		{Path: "a/b", OldTarget: "../c", NewTarget: "../x"},
+		{Path: "a/e", OldTarget: "b/d"},
+		{Path: "a/g", NewTarget: "f"},
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("mismatch:\nwant=%+v\ngot= %+v", want, changes)
+	}
+}
+
+func TestDiffLinksMissingRoot(t *testing.T) {
+	empty := fstest.MapFS{}
+	fsys := fstest.MapFS{
+		"a/b":   &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("c")},
+		"a/d/e": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../b")},
+	}
+
+	for _, test := range [...]struct {
+		old, new fs.FS
+		root     string
+		want     []fspath.LinkChange
+	}{
+		{
+			old:  empty,
+			new:  fsys,
+			root: "a",
+			want: []fspath.LinkChange{
+				{Path: "a/b", NewTarget: "c"},
+				{Path: "a/d/e", NewTarget: "../b"},
+			},
+		},
+
+		{
+			old:  fsys,
+			new:  empty,
+			root: "a",
+			want: []fspath.LinkChange{
+				{Path: "a/b", OldTarget: "c"},
+				{Path: "a/d/e", OldTarget: "../b"},
+			},
+		},
+
+		{
+			old:  empty,
+			new:  fsys,
+			root: "a/d",
+			want: []fspath.LinkChange{
+				{Path: "a/d/e", NewTarget: "../b"},
+			},
+		},
+
+		{
+			old:  empty,
+			new:  empty,
+			root: "a",
+		},
+	} {
+		changes, err := fspath.DiffLinks(test.old, test.new, test.root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(changes, test.want) {
+			t.Errorf("%s: mismatch:\nwant=%+v\ngot= %+v", test.root, test.want, changes)
+		}
+	}
+}
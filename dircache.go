@@ -0,0 +1,110 @@
+package fspath
+
+import (
+	"io/fs"
+	"sync"
+	"time"
+)
+
+// WithDirCache enables caching the names of the entries of the directories
+// traversed while resolving paths, which is only used to answer negative
+// lookups. When entering a directory, its entries are read at once with
+// ReadDir, and resolving a path through a directory missing from the listing
+// fails with an error wrapping fs.ErrNotExist without calling the file system
+// again. This amortizes the cost of requests for files which do not exist
+// (e.g. a server answering them) on file systems where ReadDir is cheap.
+//
+// The cache does not answer whether entries are symbolic links: the types
+// reported by the listing are not used, and every component found in it is
+// still probed with ReadLink. A directory replaced by a symbolic link during
+// the lifetime of the listing would otherwise be accessed through the link by
+// file systems which follow links themselves (e.g. os.DirFS), escaping the
+// root. The final component of paths is never answered from the cache either.
+//
+// When combined with WithFallback, the listing is the union of the entries of
+// the directory in both file systems.
+//
+// Like WithLinkCache, the option is intended to be passed to RootFS or New so
+// the cache is shared by all the resolutions made with the returned value.
+// Listings are keyed on the identity of the file system that paths are
+// resolved in, and remembered for ttl; directories created during that time
+// are reported as missing until the listing expires.
+func WithDirCache(ttl time.Duration) Option {
+	return func(o *options) { o.dirCache = &dirCache{ttl: ttl} }
+}
+
+// maxDirCacheEntries bounds the number of listings held by a directory cache,
+// expired listings are evicted when it is reached, and the cache is reset if
+// none of them had expired.
+const maxDirCacheEntries = 1 << 10
+
+// dirCache maps the paths of directories, keyed like the link cache, to the
+// names of their entries.
+type dirCache struct {
+	ttl     time.Duration
+	mutex   sync.Mutex
+	entries map[linkCacheKey]dirCacheEntry
+}
+
+type dirCacheEntry struct {
+	names   map[string]struct{}
+	expires time.Time
+}
+
+// lookup returns the names of the entries of the directory at key, which fsys
+// is positioned on. The boolean is false if the directory could not be read.
+func (c *dirCache) lookup(fsys fs.FS, key linkCacheKey) (map[string]struct{}, bool) {
+	c.mutex.Lock()
+	entry, ok := c.entries[key]
+	c.mutex.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.names, true
+	}
+
+	names, ok := readDirNames(fsys)
+	if !ok {
+		return nil, false
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	now := time.Now()
+	if len(c.entries) >= maxDirCacheEntries {
+		for key, entry := range c.entries {
+			if !now.Before(entry.expires) {
+				delete(c.entries, key)
+			}
+		}
+		if len(c.entries) >= maxDirCacheEntries {
+			c.entries = nil
+		}
+	}
+	if c.entries == nil {
+		c.entries = make(map[linkCacheKey]dirCacheEntry)
+	}
+	c.entries[key] = dirCacheEntry{names: names, expires: now.Add(c.ttl)}
+	return names, true
+}
+
+// readDirNames lists the names of the entries of the directory that fsys is
+// positioned on, merging both layers of a fallbackFS.
+func readDirNames(fsys fs.FS) (map[string]struct{}, bool) {
+	layers := []fs.FS{fsys}
+	if f, ok := fsys.(fallbackFS); ok {
+		layers = []fs.FS{f.primary, f.fallback}
+	}
+	names := make(map[string]struct{})
+	for _, layer := range layers {
+		if layer == nil {
+			continue
+		}
+		entries, err := fs.ReadDir(layer, ".")
+		if err != nil {
+			return nil, false
+		}
+		for _, e := range entries {
+			names[e.Name()] = struct{}{}
+		}
+	}
+	return names, true
+}
@@ -0,0 +1,156 @@
+package fspath_test
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stealthrocket/fspath"
+	"github.com/stealthrocket/fstest"
+)
+
+func TestWithDirCache(t *testing.T) {
+	fsys := &countFS{fsys: fstest.MapFS{
+		"a":   &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"a/b": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../c")},
+		"a/x": &fstest.MapFile{Mode: 0644},
+		"a/y": &fstest.MapFile{Mode: 0644},
+		"c":   &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"c/d": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+	}}
+	r := fspath.New(fspath.WithDirCache(time.Hour))
+
+	for _, test := range []struct {
+		name      string
+		path      string
+		err       error
+		readDirs  int
+		readLinks int
+	}{
+		// Components found in the listings are still probed.
+		{name: "a/x", path: "a/x", readDirs: 1, readLinks: 2},
+		{name: "a/y", path: "a/y", readLinks: 2},
+		{name: "a/b/d", path: "c/d", readDirs: 1, readLinks: 4},
+		// Missing directories are reported from the listings.
+		{name: "a/missing/d", err: fs.ErrNotExist, readLinks: 1},
+		{name: "missing/d", err: fs.ErrNotExist},
+	} {
+		fsys.reset()
+		res, err := r.Resolve(fsys, test.name)
+		if test.err != nil {
+			if !errors.Is(err, test.err) {
+				t.Errorf("%s: expected %v, got %v", test.name, test.err, err)
+			}
+		} else if err != nil {
+			t.Fatal(err)
+		} else if res.Path != test.path {
+			t.Errorf("%s: wrong path: want=%q got=%q", test.name, test.path, res.Path)
+		}
+		opens, readDirs, readLinks := fsys.count("open"), fsys.count("readdir"), fsys.count("readlink")
+		if opens != 0 || readDirs != test.readDirs || readLinks != test.readLinks {
+			t.Errorf("%s: wrong number of calls: want=0/%d/%d got=%d/%d/%d", test.name,
+				test.readDirs, test.readLinks, opens, readDirs, readLinks)
+		}
+	}
+}
+
+func TestWithDirCacheFallback(t *testing.T) {
+	// The file systems are pointers so the layers can be identified and the
+	// listings cached.
+	primary := &countFS{fsys: fstest.MapFS{
+		"a/x": &fstest.MapFile{Mode: 0644},
+	}}
+	fallback := &countFS{fsys: fstest.MapFS{
+		"a/f/g": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+	}}
+	r := fspath.New(fspath.WithDirCache(time.Hour), fspath.WithFallback(fallback))
+
+	// The directory exists only in the fallback, the listing of its parent
+	// in the primary must not report it missing.
+	for i := 0; i < 2; i++ {
+		res, err := r.Resolve(primary, "a/f/g")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Path != "a/f/g" {
+			t.Errorf("wrong path: %q", res.Path)
+		}
+	}
+	if primary.count("readdir") == 0 {
+		t.Error("the directory listing was not used")
+	}
+	if _, err := r.Resolve(primary, "a/missing/g"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected fs.ErrNotExist, got %v", err)
+	}
+}
+
+func TestWithDirCacheReplacedDirectory(t *testing.T) {
+	tmp := t.TempDir()
+	root := filepath.Join(tmp, "root")
+	if err := os.MkdirAll(filepath.Join(root, "dir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "dir", "file"), []byte("file"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(tmp, "outside"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tmp, "outside", "secret"), []byte("SECRET"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fsys := fspath.RootFS(fspath.DirFS(root), fspath.WithDirCache(time.Hour))
+	if _, err := fs.ReadFile(fsys, "dir/file"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The directory is replaced by a link pointing outside of the root while
+	// the listing of the root is cached.
+	if err := os.RemoveAll(filepath.Join(root, "dir")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("../outside", filepath.Join(root, "dir")); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := fs.ReadFile(fsys, "dir/secret"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected fs.ErrNotExist, got %q (%v)", b, err)
+	}
+}
+
+func BenchmarkWithDirCache(b *testing.B) {
+	// Half of the lookups are made for directories that do not exist, like
+	// a server answering requests for missing files would.
+	files := fstest.MapFS{
+		"static": &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+	}
+	names := make([]string, 1000)
+	for i := range names {
+		names[i] = fmt.Sprintf("static/%d/index.html", i)
+		if i%2 == 0 {
+			files[names[i]] = &fstest.MapFile{Mode: 0644}
+		}
+	}
+
+	for _, test := range []struct {
+		name string
+		opts []fspath.Option
+	}{
+		{name: "default"},
+		{name: "cache", opts: []fspath.Option{fspath.WithDirCache(time.Minute)}},
+	} {
+		b.Run(test.name, func(b *testing.B) {
+			fsys := &countFS{fsys: files}
+			r := fspath.New(test.opts...)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				r.Resolve(fsys, names[i%len(names)])
+			}
+			b.ReportMetric(float64(fsys.count("open")+fsys.count("readdir")+fsys.count("readlink"))/float64(b.N), "calls/op")
+		})
+	}
+}
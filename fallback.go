@@ -0,0 +1,111 @@
+package fspath
+
+import (
+	"errors"
+	"io/fs"
+
+	"github.com/stealthrocket/fslink"
+)
+
+// WithFallback configures a secondary file system in which path components are
+// looked up when they do not exist in the primary file system, for example to
+// layer a user configuration directory over a system one.
+//
+// The precedence rules are applied to each path component independently:
+//
+//   - a name present in the primary file system always shadows the same name
+//     in the fallback, regardless of the type of files
+//   - a name absent from the primary file system is looked up in the fallback
+//   - when a directory exists in both, the names it contains are looked up in
+//     the primary first, then in the fallback
+//
+// Symbolic links are read from the file system which provided the path
+// component, and their targets are resolved following the same rules, from the
+// root of both file systems. Only the not-found case falls through: any other
+// error returned by the primary file system aborts the resolution. Directory
+// listings are not merged.
+func WithFallback(fallback fs.FS) Option {
+	return func(o *options) { o.fallback = fallback }
+}
+
+// fallbackFS is a layer of two file systems positioned on the same directory,
+// either of which may be nil if the directory exists only in the other one.
+type fallbackFS struct {
+	primary  fs.FS
+	fallback fs.FS
+}
+
+// layer returns the file system which provides name.
+func (fsys fallbackFS) layer(name string) fs.FS {
+	if fsys.inPrimary(name) {
+		return fsys.primary
+	}
+	return fsys.fallback
+}
+
+func (fsys fallbackFS) inPrimary(name string) bool {
+	if fsys.fallback == nil {
+		return true
+	}
+	if fsys.primary == nil {
+		return false
+	}
+	_, err := lstat(fsys.primary, name)
+	return !errors.Is(err, fs.ErrNotExist)
+}
+
+func (fsys fallbackFS) Open(name string) (fs.File, error) {
+	return fsys.layer(name).Open(name)
+}
+
+func (fsys fallbackFS) Stat(name string) (fs.FileInfo, error) {
+	return fs.Stat(fsys.layer(name), name)
+}
+
+func (fsys fallbackFS) ReadLink(name string) (string, error) {
+	return fslink.ReadLink(fsys.layer(name), name)
+}
+
+func (fsys fallbackFS) Sub(dir string) (fs.FS, error) {
+	if dir == "." {
+		return fsys, nil
+	}
+	if fsys.inPrimary(dir) {
+		primary, err := fslink.Sub(fsys.primary, dir)
+		if err != nil {
+			return nil, err
+		}
+		return fallbackFS{primary: primary, fallback: fsys.subFallback(dir)}, nil
+	}
+	fallback, err := fslink.Sub(fsys.fallback, dir)
+	if err != nil {
+		return nil, err
+	}
+	return fallbackFS{fallback: fallback}, nil
+}
+
+// subFallback returns the fallback file system positioned on dir, or nil if
+// dir is not a directory of the fallback.
+//
+// The directory is shadowed by the primary, so a symbolic link found in its
+// place in the fallback is never resolved and is treated as absent; following
+// it would position the fallback outside of its root.
+func (fsys fallbackFS) subFallback(dir string) fs.FS {
+	if fsys.fallback == nil {
+		return nil
+	}
+	if info, err := lstat(fsys.fallback, dir); err != nil || !info.IsDir() {
+		return nil
+	}
+	sub, err := fslink.Sub(fsys.fallback, dir)
+	if err != nil {
+		return nil
+	}
+	return sub
+}
+
+var (
+	_ fs.StatFS         = fallbackFS{}
+	_ fs.SubFS          = fallbackFS{}
+	_ fslink.ReadLinkFS = fallbackFS{}
+)
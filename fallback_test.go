@@ -0,0 +1,75 @@
+package fspath_test
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stealthrocket/fspath"
+	"github.com/stealthrocket/fstest"
+)
+
+func TestWithFallback(t *testing.T) {
+	primary := fstest.MapFS{
+		"a":   &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"a/b": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../c")},
+		"c/e": &fstest.MapFile{Mode: 0644, Data: []byte("primary")},
+	}
+	fallback := fstest.MapFS{
+		"a/b": &fstest.MapFile{Mode: 0644, Data: []byte("shadowed")},
+		"c/d": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+		"c/e": &fstest.MapFile{Mode: 0644, Data: []byte("fallback")},
+	}
+
+	for _, test := range [...]struct {
+		name string
+		data string
+	}{
+		{name: "a/b/d", data: "Hello World!"},
+		{name: "a/b/e", data: "primary"},
+		{name: "c/d", data: "Hello World!"},
+	} {
+		dir, base, err := fspath.LookupWith(primary, test.name, fspath.WithFallback(fallback))
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		b, err := fs.ReadFile(dir, base)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if string(b) != test.data {
+			t.Errorf("%s: wrong file content: want=%q got=%q", test.name, test.data, b)
+		}
+	}
+
+	if _, err := fspath.ReadFile(primary, "a/b/d"); err == nil {
+		t.Error("expected an error without a fallback")
+	}
+}
+
+func TestWithFallbackEscapingDirectory(t *testing.T) {
+	tmp := t.TempDir()
+	for _, dir := range []string{"primary/etc", "fallback", "outside"} {
+		if err := os.MkdirAll(filepath.Join(tmp, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(tmp, "outside", "secret"), []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("../outside", filepath.Join(tmp, "fallback", "etc")); err != nil {
+		t.Fatal(err)
+	}
+
+	fsys := fspath.RootFS(fspath.DirFS(filepath.Join(tmp, "primary")),
+		fspath.WithFallback(fspath.DirFS(filepath.Join(tmp, "fallback"))),
+	)
+	b, err := fspath.ReadFile(fsys, "etc/secret")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected fs.ErrNotExist, got %q (%v)", b, err)
+	}
+}
@@ -0,0 +1,54 @@
+package fspath
+
+import (
+	"io"
+	"io/fs"
+)
+
+// OpenFollowing opens the file at name in fsys, following symbolic links, and
+// returns a reader which resolves name again when reaching the end of the
+// file. If name then resolves to a different file, for example because it is a
+// link which was retargeted to a new file during log rotation, the reader
+// closes the previous file and continues reading from the start of the new
+// one.
+//
+// Each call to Read resolves name again at most once, and returns io.EOF if
+// the new file has no data either, or if name cannot be resolved or opened,
+// which lets the caller decide when to retry reading. The returned reader must
+// be closed to release the file that it holds.
+func OpenFollowing(fsys fs.FS, name string) (io.ReadCloser, error) {
+	f, canonical, err := OpenCanonical(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	return &followingReader{fsys: fsys, name: name, file: f, path: canonical}, nil
+}
+
+type followingReader struct {
+	fsys fs.FS
+	name string
+	file fs.File
+	path string
+}
+
+func (r *followingReader) Read(b []byte) (int, error) {
+	n, err := r.file.Read(b)
+	if err != io.EOF || n > 0 {
+		return n, err
+	}
+	res, resolveErr := defaultOptions.resolve(r.fsys, r.name)
+	if resolveErr != nil || res.path() == r.path {
+		return 0, io.EOF
+	}
+	f, openErr := res.fsys.Open(res.base)
+	if openErr != nil {
+		return 0, io.EOF
+	}
+	r.file.Close()
+	r.file, r.path = f, res.path()
+	return r.file.Read(b)
+}
+
+func (r *followingReader) Close() error {
+	return r.file.Close()
+}
@@ -0,0 +1,55 @@
+package fspath_test
+
+import (
+	"io"
+	"io/fs"
+	"testing"
+
+	"github.com/stealthrocket/fspath"
+	"github.com/stealthrocket/fstest"
+)
+
+func TestOpenFollowing(t *testing.T) {
+	fsys := fstest.MapFS{
+		"current.log": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("logs/1.log")},
+		"logs/1.log":  &fstest.MapFile{Mode: 0644, Data: []byte("first\n")},
+		"logs/2.log":  &fstest.MapFile{Mode: 0644, Data: []byte("second\n")},
+	}
+
+	r, err := fspath.OpenFollowing(fsys, "current.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	for _, want := range []string{"first\n", ""} {
+		b, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != want {
+			t.Errorf("wrong content: want=%q got=%q", want, b)
+		}
+	}
+
+	// Rotate the log, the reader continues from the new file once it reaches
+	// the end of the previous one.
+	fsys["current.log"] = &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("logs/2.log")}
+
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "second\n" {
+		t.Errorf("wrong content: want=%q got=%q", "second\n", b)
+	}
+
+	// A dangling link during rotation is reported as the end of the file.
+	fsys["current.log"] = &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("logs/3.log")}
+
+	if b, err := io.ReadAll(r); err != nil {
+		t.Error(err)
+	} else if len(b) != 0 {
+		t.Errorf("unexpected content: %q", b)
+	}
+}
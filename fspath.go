@@ -1,10 +1,15 @@
 package fspath
 
 import (
+	"bytes"
+	"context"
 	"errors"
+	"fmt"
 	"io/fs"
 	"path"
+	"reflect"
 	"strings"
+	"time"
 
 	"github.com/stealthrocket/fslink"
 )
@@ -13,38 +18,298 @@ var (
 	// ErrLoop is returned when attempting to resolve paths that have followed
 	// too many symbolic links.
 	ErrLoop = errors.New("loop")
+
+	// ErrEscape is returned when a path or a symbolic link points above the
+	// root of a file system.
+	ErrEscape = errors.New("escape")
+
+	// ErrNotOSFile is returned by OpenOSFile when the file system does not
+	// expose files of the underlying operating system.
+	ErrNotOSFile = errors.New("not an os file")
+
+	// ErrBudgetExceeded is returned when resolving a path requires more
+	// operations than the limit configured with WithMaxOps.
+	ErrBudgetExceeded = errors.New("budget exceeded")
+
+	// ErrUnsupported is returned when an operation requires a capability that
+	// the underlying file system does not have.
+	ErrUnsupported = errors.New("unsupported operation")
+
+	// ErrDangling is returned by ResolveDangling when the file that a path
+	// resolves to does not exist.
+	ErrDangling = errors.New("dangling")
+
+	// ErrTooDeep is returned when resolving a path which has more components
+	// than the limit configured with WithMaxDepth.
+	ErrTooDeep = errors.New("path too deep")
 )
 
+// ReadLinkFS is the interface implemented by file systems which support symbolic
+// links. It matches the fs.ReadLinkFS interface proposed in #49580, and will be
+// replaced by it once it is available in the minimum Go version supported by
+// this package.
+type ReadLinkFS interface {
+	fs.FS
+	// ReadLink returns the target of the symbolic link at name.
+	ReadLink(name string) (string, error)
+	// Lstat returns information about the file at name without following
+	// symbolic links.
+	Lstat(name string) (fs.FileInfo, error)
+}
+
+// DynamicLinkFS is the interface implemented by file systems which compute the
+// targets of some symbolic links dynamically, like /proc/self on Linux.
+//
+// DynamicReadLink is consulted before ReadLink when resolving each component
+// of a path. It returns the target of the link at name, and a boolean set to
+// true if name must be treated as a link. When the boolean is false, the
+// resolution falls back to calling ReadLink if the file system implements it.
+//
+// The views of the file system returned by Sub should also implement the
+// interface for links located in sub-directories to be resolved dynamically.
+type DynamicLinkFS interface {
+	fs.FS
+	DynamicReadLink(name string) (string, bool, error)
+}
+
+// LoopError is returned when Lookup detects that symbolic links are looping on
+// each other while resolving Path. The Chain field contains the path of every
+// link that was followed, in order.
+//
+// LoopError unwraps to ErrLoop.
+type LoopError struct {
+	Path  string
+	Chain []string
+}
+
+func (e *LoopError) Error() string {
+	msg := "lookup " + e.Path + ": " + ErrLoop.Error()
+	if len(e.Chain) > 0 {
+		msg += " (" + strings.Join(e.Chain, " -> ") + ")"
+	}
+	return msg
+}
+
+func (e *LoopError) Unwrap() error { return ErrLoop }
+
+// PermissionError is returned when Lookup is denied access to a path, it
+// reports whether the access was denied while traversing the directories
+// leading to the file, or on the file itself.
+//
+// PermissionError unwraps to the error returned by the file system, which
+// wraps fs.ErrPermission.
+type PermissionError struct {
+	// Path of the file or directory that access was denied to, relative to the
+	// root of the file system.
+	Path string
+	// Final is true if access was denied on the file being resolved, and false
+	// if it was denied traversing one of the directories leading to it.
+	Final bool
+	Err   error
+}
+
+func (e *PermissionError) Error() string {
+	op := "traverse "
+	if e.Final {
+		op = "access "
+	}
+	return op + e.Path + ": " + e.Err.Error()
+}
+
+func (e *PermissionError) Unwrap() error { return e.Err }
+
+// permissionError tags err with the path that access was denied to if it is a
+// permission error which was not already tagged.
+func permissionError(err error, name string, final bool) error {
+	var perm *PermissionError
+	if errors.Is(err, fs.ErrPermission) && !errors.As(err, &perm) {
+		if name == "" {
+			name = "."
+		}
+		return &PermissionError{Path: name, Final: final, Err: err}
+	}
+	return err
+}
+
 func Open(fsys fs.FS, name string) (fs.File, error) {
-	return lookup(fsys, name, fs.FS.Open)
+	return lookup(defaultOptions, fsys, name, fs.FS.Open)
 }
 
 func Stat(fsys fs.FS, name string) (fs.FileInfo, error) {
-	return lookup(fsys, name, fs.Stat)
+	return lookup(defaultOptions, fsys, name, fs.Stat)
 }
 
 func Sub(fsys fs.FS, name string) (fs.FS, error) {
-	return lookup(fsys, name, fslink.Sub)
+	return lookup(defaultOptions, fsys, name, fslink.Sub)
 }
 
 func ReadDir(fsys fs.FS, name string) ([]fs.DirEntry, error) {
-	return lookup(fsys, name, fs.ReadDir)
+	return lookup(defaultOptions, fsys, name, fs.ReadDir)
 }
 
 func ReadFile(fsys fs.FS, name string) ([]byte, error) {
-	return lookup(fsys, name, fs.ReadFile)
+	return lookup(defaultOptions, fsys, name, fs.ReadFile)
 }
 
+// ReadLink returns the target of the symbolic link at name in fsys. Symbolic
+// links are followed on every component of the path except the last one.
+//
+// If the final component is not a symbolic link, the function returns a
+// *fs.PathError wrapping fs.ErrInvalid, matching the behavior of readlink(2)
+// regardless of how the underlying file system reports it.
 func ReadLink(fsys fs.FS, name string) (string, error) {
-	return lookup(fsys, name, fslink.ReadLink)
+	return defaultOptions.readLink(fsys, name)
 }
 
-func lookup[F func(fs.FS, string) (R, error), R any](fsys fs.FS, name string, fn F) (ret R, err error) {
-	sub, base, err := Lookup(fsys, name)
+func (opts *options) readLink(fsys fs.FS, name string) (string, error) {
+	dir, base, err := opts.lookupParent(fsys, name)
+	if err != nil {
+		return "", err
+	}
+	link, err := readLinkTarget(dir, base)
+	if err != nil {
+		// Some file systems report ErrNotExist or other errors when reading a
+		// file which is not a link, we use Stat to tell whether the file exists
+		// and normalize the error.
+		if errors.Is(err, fs.ErrInvalid) || exists(dir, base) {
+			err = &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+		}
+		return "", err
+	}
+	return link, nil
+}
+
+// Lstat is like Stat but when the final component of name is a symbolic link,
+// it returns information about the link itself instead of following it.
+func Lstat(fsys fs.FS, name string) (fs.FileInfo, error) {
+	return defaultOptions.lstat(fsys, name)
+}
+
+func (opts *options) lstat(fsys fs.FS, name string) (fs.FileInfo, error) {
+	dir, base, err := opts.lookupParent(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	return lstat(dir, base)
+}
+
+// readLinkTarget is like fslink.ReadLink but it validates the target once
+// cleaned, the way the resolution does, so links like "./d" are returned as-is
+// instead of being rejected.
+func readLinkTarget(dir fs.FS, base string) (string, error) {
+	f, ok := dir.(fslink.ReadLinkFS)
+	if !ok {
+		return fslink.ReadLink(dir, base)
+	}
+	link, err := f.ReadLink(base)
+	if err != nil {
+		return "", err
+	}
+	switch target := path.Clean(link); {
+	case target == "..":
+	case strings.HasPrefix(target, "../"):
+	case fs.ValidPath(target):
+	default:
+		return "", &fs.PathError{Op: "readlink", Path: base, Err: fmt.Errorf("malformed link target: %q: %w", link, fs.ErrInvalid)}
+	}
+	return link, nil
+}
+
+// lstat returns information about the file at name in fsys without following
+// symbolic links. The name must be a file in the root directory of fsys.
+func lstat(dir fs.FS, base string) (fs.FileInfo, error) {
+	if f, ok := dir.(interface {
+		Lstat(string) (fs.FileInfo, error)
+	}); ok {
+		return f.Lstat(base)
+	}
+	if _, err := readLinkTarget(dir, base); err != nil {
+		return fs.Stat(dir, base)
+	}
+	// The file system has no way to retrieve information about the link
+	// itself, but directory entries are not expected to follow links.
+	entries, err := fs.ReadDir(dir, ".")
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entry.Name() == base {
+			return entry.Info()
+		}
+	}
+	return nil, &fs.PathError{Op: "lstat", Path: base, Err: fs.ErrNotExist}
+}
+
+func lookup[F func(fs.FS, string) (R, error), R any](opts *options, fsys fs.FS, name string, fn F) (ret R, err error) {
+	r, err := opts.resolve(fsys, name)
 	if err != nil {
 		return ret, err
 	}
-	return fn(sub, base)
+	if r.link {
+		// Like opening a symbolic link with O_NOFOLLOW on posix systems.
+		return ret, &fs.PathError{Op: "lookup", Path: name, Err: ErrLoop}
+	}
+	if ret, err = fn(r.fsys, r.base); err != nil {
+		err = permissionError(err, r.path(), true)
+	}
+	return ret, err
+}
+
+// lookupParent is like Lookup but it does not follow symbolic links on the last
+// component of name.
+func (opts *options) lookupParent(fsys fs.FS, name string) (fs.FS, string, error) {
+	if err := checkPath(name); err != nil {
+		return nil, "", &fs.PathError{Op: "lookup", Path: name, Err: err}
+	}
+	dir, base := path.Split(name)
+	if dir == "" {
+		return fsys, base, nil
+	}
+	r, err := opts.resolveFollow(fsys, dir[:len(dir)-1], true)
+	if err != nil {
+		return nil, "", err
+	}
+	sub, err := subDir(r.fsys, r.base)
+	if err != nil {
+		return nil, "", err
+	}
+	return sub, base, nil
+}
+
+func exists(fsys fs.FS, name string) bool {
+	_, err := fs.Stat(fsys, name)
+	return err == nil
+}
+
+// errNotDir is the equivalent of ENOTDIR, it wraps fs.ErrInvalid since there
+// is no dedicated error in the standard library.
+var errNotDir = fmt.Errorf("not a directory: %w", fs.ErrInvalid)
+
+// errNotLink is used internally when a file is known not to be a symbolic link
+// without calling ReadLink.
+var errNotLink = fmt.Errorf("not a symbolic link: %w", fs.ErrInvalid)
+
+// abortError is returned by the ReadLink method of file systems which need the
+// resolution to fail with err as-is, instead of interpreting it as it would
+// with other errors (e.g. treating fs.ErrInvalid as the file not being a link).
+type abortError struct{ err error }
+
+func (e *abortError) Error() string { return e.err.Error() }
+
+// isDirEntryLink returns false if the directory entries of fsys indicate that
+// name is not a symbolic link. When the information is not available, it
+// returns true to let the caller read the link and find out.
+func isDirEntryLink(fsys fs.FS, name string) bool {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return true
+	}
+	for _, entry := range entries {
+		if entry.Name() == name {
+			return entry.Type() == fs.ModeSymlink
+		}
+	}
+	return true
 }
 
 // Sentinel error used to stop walking through paths when encountering symoblic
@@ -64,46 +329,409 @@ var symlink = errors.New("symlink")
 // therefore be used as a sandboxing mechanism to prevent escaping the bounds
 // of a read-only file system; beware that if the underlying file system can
 // be modified concurrently, these guarantees do no apply anymore!
+// Snapshot can be used to obtain a consistent view of file systems which
+// support it.
+//
+// The name must be a valid path according to fs.ValidPath, no decoding is done
+// on the input (e.g. percent-encoded sequences or backslashes are interpreted
+// literally). Invalid names cause the function to return an error wrapping
+// fs.ErrInvalid which describes the rule that was violated. CleanName can be
+// used to turn untrusted names into valid paths beforehand.
+//
+// When access to one of the directories traversed is denied, the error is a
+// *PermissionError reporting the directory. The functions accessing the file
+// after resolving its path (e.g. Open, Stat) also report permission errors
+// occurring on the file itself as a *PermissionError, with Final set to true.
 func Lookup(fsys fs.FS, name string) (fs.FS, string, error) {
-	if !fs.ValidPath(name) {
-		return nil, "", &fs.PathError{"lookup", name, fs.ErrNotExist}
+	return LookupWith(fsys, name)
+}
+
+// LookupWith is like Lookup but the resolution can be configured by passing
+// options.
+func LookupWith(fsys fs.FS, name string, opts ...Option) (fs.FS, string, error) {
+	r, err := newOptions(opts).resolve(fsys, name)
+	return r.fsys, r.base, err
+}
+
+// resolution is the result of resolving a path in a file system.
+type resolution struct {
+	fsys fs.FS  // view of the directory containing the file
+	base string // base name of the file in fsys
+	dir  string // path of the directory relative to the file system root
+	// atRoot is true if the ".." segments of a link walked back up to the
+	// root of the file system.
+	atRoot bool
+	// links is the number of symbolic links followed.
+	links int
+	// link is true if the final component is a symbolic link which was not
+	// followed because of WithFollowFinal.
+	link bool
+	// walk is the stack of views of the parent directories of fsys, from the
+	// root of the file system.
+	walk []fs.FS
+}
+
+// linkPosition is a position reached while resolving a path, used to detect
+// cycles of symbolic links.
+type linkPosition struct {
+	root any // identity of the file system root, nil for the original one
+	name string
+}
+
+// fsIdentity returns a comparable value identifying fsys. File systems that
+// are maps (e.g. fstest.MapFS) or pointers are identified by their address,
+// other comparable values by themselves. The boolean is false when fsys cannot
+// be identified.
+func fsIdentity(fsys fs.FS) (any, bool) {
+	v := reflect.ValueOf(fsys)
+	switch v.Kind() {
+	case reflect.Map, reflect.Pointer, reflect.Chan, reflect.UnsafePointer:
+		return fsAddress{typ: v.Type(), ptr: v.Pointer()}, true
+	}
+	if v.Comparable() {
+		return fsys, true
+	}
+	return nil, false
+}
+
+type fsAddress struct {
+	typ reflect.Type
+	ptr uintptr
+}
+
+// unidentifiedFS is used in place of the identity of file systems which cannot
+// be identified, it never matches the identity of other file systems.
+type unidentifiedFS int
+
+// depth returns the number of components in name.
+func depth(name string) int {
+	if name == "." || name == "" {
+		return 0
+	}
+	return strings.Count(name, "/") + 1
+}
+
+func (r *resolution) path() string { return path.Join(r.dir, r.base) }
+
+// LookupBudget is like Lookup but the number of symbolic links that can be
+// followed is limited by budget instead of the default limit. The function
+// returns the remaining budget, allowing a sequence of lookups to share the
+// same limit, for example when resolving a working directory and a path
+// relative to it:
+//
+//	cwd, base, budget, err := fspath.LookupBudget(fsys, dir, 40)
+//	...
+//	dir, base, budget, err := fspath.LookupBudget(cwd, name, budget)
+//
+// When the budget is exhausted, the lookup fails with a *LoopError. Negative
+// budgets are rejected with an error wrapping fs.ErrInvalid.
+func LookupBudget(fsys fs.FS, name string, budget int) (fs.FS, string, int, error) {
+	if budget < 0 {
+		return nil, "", budget, &fs.PathError{Op: "lookup", Path: name, Err: fs.ErrInvalid}
+	}
+	r, err := newOptions([]Option{withMaxLinks(budget)}).resolve(fsys, name)
+	return r.fsys, r.base, budget - r.links, err
+}
+
+func (opts *options) resolve(fsys fs.FS, name string) (resolution, error) {
+	return opts.resolveFollow(fsys, name, !opts.noFollowFinal)
+}
+
+// resolveFollow resolves name in fsys, following the symbolic link on the final
+// component of name only if follow is true.
+func (opts *options) resolveFollow(fsys fs.FS, name string, follow bool) (r resolution, err error) {
+	if err := checkPath(name); err != nil {
+		return resolution{}, &fs.PathError{Op: "lookup", Path: name, Err: err}
+	}
+	if err := opts.canceled(); err != nil {
+		return resolution{}, &fs.PathError{Op: "lookup", Path: name, Err: err}
+	}
+	if opts.maxDepth > 0 && depth(name) > opts.maxDepth {
+		return resolution{}, &fs.PathError{Op: "lookup", Path: name, Err: ErrTooDeep}
+	}
+	if opts.fallback != nil {
+		fsys = fallbackFS{primary: fsys, fallback: opts.fallback}
 	}
 
 	walk := make([]fs.FS, 0, 16)
-	loop := 0
+	dirs := make([]string, 0, 16)
+	ops := 0
+	origin, originFS := name, fsys
+	// Recorded paths are relative to the root of the resolution, they would
+	// be ambiguous if links could redirect it to other file systems.
+	if opts.recorder != nil && opts.absoluteLinks != nil {
+		return resolution{}, &fs.PathError{Op: "lookup", Path: name, Err: ErrUnsupported}
+	}
+
+	// Each call to the underlying file system counts toward the budget of
+	// operations configured with WithMaxOps, and is an opportunity to check
+	// whether the context configured with WithContext was canceled, or the
+	// deadline set by Deadline has passed.
+	op := func() error {
+		if ops++; opts.maxOps > 0 && ops > opts.maxOps {
+			return &fs.PathError{Op: "lookup", Path: origin, Err: ErrBudgetExceeded}
+		}
+		if err := opts.canceled(); err != nil {
+			return &fs.PathError{Op: "lookup", Path: origin, Err: err}
+		}
+		return nil
+	}
+
+	// Calls to the underlying file system are retried when the resolution was
+	// configured with WithRetry, each new attempt counting as an operation.
+	retry := func(call func() error) error {
+		for attempt := 1; ; attempt++ {
+			err := call()
+			if err == nil || attempt >= opts.attempts || !opts.transient(err) {
+				return err
+			}
+			if err := opts.sleep(opts.backoff); err != nil {
+				return &fs.PathError{Op: "lookup", Path: origin, Err: err}
+			}
+			if err := op(); err != nil {
+				return err
+			}
+		}
+	}
+
+	// The chain records the links followed during the resolution, and the seen
+	// set is used to detect cycles; resolving the same name from the same
+	// position twice means that links are looping on each other. Positions are
+	// keyed on the identity of the file system that the resolution is rooted
+	// at and the full path name relative to it, so links oscillating between
+	// names of the same directory are detected as well as links redirecting
+	// back and forth between file systems.
+	var chain []string
+	var seen map[linkPosition]struct{}
+	var rootID any // nil until redirected to another file system
+	var redirects int
+	// The caches are shared by resolutions made in different file systems,
+	// their entries are keyed on the identity of the root of the resolution,
+	// and they are not used when it cannot be identified.
+	var cacheRoot any
+	var cacheable bool
+	if opts.linkCache != nil || opts.dirCache != nil {
+		cacheRoot, cacheable = fsIdentity(fsys)
+	}
+	var clamps int
+	var atRoot bool
+	var finalLink bool
+
+	if opts.stats != nil {
+		defer func() {
+			opts.stats.add(len(chain), clamps, ops, errors.Is(err, ErrLoop))
+		}()
+	}
+
+	// File systems which do not support symbolic links cannot cause the path
+	// to be rewritten, so the resolution only needs to position the file
+	// system on the parent directory, which is done with a single call to Sub.
+	// The same applies when the caller asserted that the path was canonical
+	// with WithAssumeCanonical.
+	_, readLink := fsys.(fslink.ReadLinkFS)
+	_, dynamic := fsys.(DynamicLinkFS)
+	if (!(readLink || dynamic) || opts.assumeCanonical) && opts.onDir == nil && opts.accessCheck == nil && !opts.forceWalk {
+		dir, base := path.Split(name)
+		if dir == "" {
+			return resolution{fsys: fsys, base: base}, nil
+		}
+		dir = dir[:len(dir)-1]
+		if err = op(); err != nil {
+			return resolution{}, err
+		}
+		var sub fs.FS
+		if err = retry(func() (err error) {
+			sub, err = fslink.Sub(fsys, dir)
+			return err
+		}); err != nil {
+			return resolution{}, permissionError(err, dir, false)
+		}
+		return resolution{fsys: sub, base: base, dir: dir}, nil
+	}
 
 	for {
-		// 40 is the maximum number of symbolic link lookups allowed by Linux,
-		// assume there was a valid reason behind picking this value and do the
-		// same so at least we are not changing the behavior of applications
-		// that would have worked when using an os.DirFS directly.
-		if loop++; loop == 40 {
-			return fsys, name, &fs.PathError{"lookup", name, ErrLoop}
+		if len(chain) > 0 {
+			key := linkPosition{root: rootID, name: path.Join(path.Join(dirs...), name)}
+			if seen == nil {
+				seen = map[linkPosition]struct{}{{name: origin}: {}}
+			}
+			if _, loop := seen[key]; loop {
+				return resolution{fsys: fsys, base: name, dir: path.Join(dirs...), atRoot: atRoot, links: len(chain)}, &LoopError{Path: origin, Chain: chain}
+			}
+			seen[key] = struct{}{}
 		}
 		if name == "." {
-			return fsys, name, nil
+			return resolution{fsys: fsys, base: name, dir: path.Join(dirs...), atRoot: atRoot, links: len(chain), walk: walk}, nil
 		}
 
-		err := Walk(name, func(prefix string) error {
+		var p *prefetcher
+		if opts.prefetch {
+			if f, ok := fsys.(fslink.ReadLinkFS); ok {
+				p = startPrefetch(f, name)
+			}
+		}
+		index := -1
+		var decision Decision
+
+		visit := func(prefix string) error {
 			base := path.Base(prefix)
+			index++
 			// There is no way to determine if the path is a symbolic link since
 			// both Open and Stat will follow links, so we opportunistically try
 			// to read the path as a link and assume that if it fails we are not
 			// in the presence of a symbolic link.
-			if f, ok := fsys.(fslink.ReadLinkFS); ok {
-				link, err := f.ReadLink(base)
+			f, readLink := fsys.(fslink.ReadLinkFS)
+			d, dynamic := fsys.(DynamicLinkFS)
+			if readLink || dynamic {
+				if err := op(); err != nil {
+					return err
+				}
+				var link string
+				var err error
+				var isDynamic bool
+				if dynamic {
+					if err := retry(func() (err error) {
+						link, isDynamic, err = d.DynamicReadLink(base)
+						return err
+					}); err != nil {
+						return err
+					}
+				}
+				switch {
+				case isDynamic:
+				case !readLink:
+					err = errNotLink
+				case p != nil:
+					link, err = p.readLink(index)
+				case opts.dirEntryLinks:
+					if err := op(); err != nil {
+						return err
+					}
+					if isDirEntryLink(fsys, base) {
+						err = retry(func() (err error) {
+							link, err = f.ReadLink(base)
+							return err
+						})
+					} else {
+						err = errNotLink
+					}
+				case opts.dirCache != nil && cacheable && len(prefix) < len(name):
+					if err := op(); err != nil {
+						return err
+					}
+					key := linkCacheKey{root: cacheRoot, name: path.Join(dirs...)}
+					names, ok := opts.dirCache.lookup(fsys, key)
+					if _, found := names[base]; ok && !found {
+						// The listing is authoritative, the directory does
+						// not need to be looked up again to confirm that it
+						// is missing.
+						return &fs.PathError{Op: "lookup", Path: path.Join(path.Join(dirs...), base), Err: fs.ErrNotExist}
+					}
+					err = retry(func() (err error) {
+						link, err = f.ReadLink(base)
+						return err
+					})
+				case opts.linkCache != nil && cacheable && len(prefix) < len(name):
+					// The final component is always probed, so a file
+					// replaced by a link is never opened in its place.
+					key := linkCacheKey{root: cacheRoot, name: path.Join(path.Join(dirs...), base)}
+					if opts.linkCache.notLink(key) {
+						err = errNotLink
+						break
+					}
+					err = retry(func() (err error) {
+						link, err = f.ReadLink(base)
+						return err
+					})
+					if errors.Is(err, fs.ErrInvalid) {
+						opts.linkCache.add(key)
+					}
+				default:
+					err = retry(func() (err error) {
+						link, err = f.ReadLink(base)
+						return err
+					})
+				}
+				var abort *abortError
+				if errors.As(err, &abort) {
+					return abort.err
+				}
+				decision.Link, decision.Target = err == nil, link
 				switch {
+				case err == nil && opts.linkFilter != nil && !opts.linkFilter(path.Join(path.Join(dirs...), base), link):
+					// The link was rejected by the filter, it is treated as
+					// a regular file instead.
+				case err == nil && !follow && len(prefix) == len(name):
+					// The link is the final component and must not be
+					// followed, the resolution stops on the link itself.
+					finalLink = true
 				case err == nil:
+					raw := link
+					rooted := false
+					if opts.windowsLinkTargets {
+						link, rooted = windowsLinkTarget(link)
+					}
+					// Cleaning the link collapses redundant slashes as well as
+					// interior "." and ".." segments lexically, only leading
+					// ".." segments remain and are resolved against the walk
+					// stack below. Targets are always cleaned before being
+					// classified.
 					link = path.Clean(link)
-					// Note: the current proposal from #49580 states that the
-					// ReadLink method should error if the link being read is
-					// absolute.
-					switch {
-					case link == "..":
-					case strings.HasPrefix(link, "../"):
-					case fs.ValidPath(link):
+					var redirect fs.FS
+					switch target, _ := ParseLinkTarget(link); target.Kind {
+					case LinkTargetDot, LinkTargetRelative, LinkTargetEscaping:
+					case LinkTargetAbsolute:
+						// Note: the current proposal from #49580 states that
+						// the ReadLink method should error if the link being
+						// read is absolute. Absolute targets are only followed
+						// when mapped to a file system chosen by the caller
+						// with WithAbsoluteLinkResolver, which then becomes the
+						// root of the resolution.
+						if opts.absoluteLinks == nil {
+							return &fs.PathError{Op: "lookup", Path: link, Err: fs.ErrNotExist}
+						}
+						root, rel, err := opts.absoluteLinks(link)
+						if err != nil {
+							return err
+						}
+						if !fs.ValidPath(rel) {
+							return &fs.PathError{Op: "lookup", Path: rel, Err: fs.ErrInvalid}
+						}
+						redirect, link = root, rel
 					default:
-						return &fs.PathError{"lookup", link, fs.ErrNotExist}
+						return &fs.PathError{Op: "lookup", Path: link, Err: fs.ErrNotExist}
+					}
+
+					if len(chain) >= opts.maxLinks {
+						return &LoopError{Path: origin, Chain: chain}
+					}
+					chain = append(chain, path.Join(path.Join(dirs...), base))
+					clamped := false
+
+					// Links converted from absolute Windows paths are relative
+					// to the root of the file system.
+					if rooted && len(walk) > 0 {
+						fsys = walk[0]
+						walk = walk[:0]
+						dirs = dirs[:0]
+						atRoot = true
+					}
+					if redirect != nil {
+						fsys = redirect
+						walk = walk[:0]
+						dirs = dirs[:0]
+						redirects++
+						id, ok := fsIdentity(redirect)
+						if !ok {
+							id = unidentifiedFS(redirects)
+						}
+						if originID, ok := fsIdentity(originFS); ok && id == originID {
+							id = nil
+						}
+						rootID = id
+						if opts.linkCache != nil || opts.dirCache != nil {
+							cacheRoot, cacheable = fsIdentity(redirect)
+						}
 					}
 
 					// When the path is relative, we turn it into an absolute
@@ -111,44 +739,152 @@ func Lookup(fsys fs.FS, name string) (fs.FS, string, error) {
 					// This might result in pointing above the root, which is
 					// collapsed as it would when resolving a path like "/.."
 					// on posix file systems.
+					hasDotDot := link == ".." || strings.HasPrefix(link, "../")
 					for len(walk) > 0 && (link == ".." || strings.HasPrefix(link, "../")) {
 						i := len(walk) - 1
 						fsys = walk[i]
 						walk = walk[:i]
+						dirs = dirs[:i]
 						link = strings.TrimPrefix(link, "..")
 						link = strings.TrimPrefix(link, "/")
 					}
 
+					if hasDotDot && len(walk) == 0 {
+						atRoot = true
+					}
+
+					// The ".." segments left once the walk stack is empty,
+					// which is always the case for links located in the root
+					// directory, point above the root and are dropped, so
+					// the rest of the link resolves from the root.
 					for link == ".." || strings.HasPrefix(link, "../") {
 						link = strings.TrimPrefix(link, "..")
 						link = strings.TrimPrefix(link, "/")
+						clamped = true
+					}
+					if clamped {
+						clamps++
+					}
+					decision.Clamped = clamped
+
+					if opts.onLink != nil {
+						opts.onLink(LinkStep{
+							Path:    chain[len(chain)-1],
+							Link:    raw,
+							Target:  path.Join(path.Join(dirs...), link),
+							Clamped: clamped,
+						})
+					}
+
+					// A link pointing to itself would loop until the limit on
+					// the number of links is reached, it is reported as soon
+					// as it is found instead.
+					if redirect == nil && path.Join(path.Join(dirs...), link) == chain[len(chain)-1] {
+						return &LoopError{Path: origin, Chain: chain}
 					}
 
 					name = strings.TrimPrefix(name, prefix)
 					name = strings.TrimPrefix(name, "/")
 					name = path.Join(link, name)
+					// When the link was only made of ".." segments and was
+					// the last component, nothing is left to resolve and the
+					// path refers to the directory that we walked back to.
+					if name == "" {
+						name = "."
+					}
+					if opts.maxDepth > 0 && len(dirs)+depth(name) > opts.maxDepth {
+						return &fs.PathError{Op: "lookup", Path: origin, Err: ErrTooDeep}
+					}
 					return symlink
 				case errors.Is(err, fs.ErrInvalid):
-				case errors.Is(err, fs.ErrNotExist):
 				default:
-					return err
+					// The error may be caused by the parent not being a
+					// directory, which we report as ENOTDIR would be on
+					// posix systems.
+					if i := len(walk) - 1; i >= 0 {
+						if err := op(); err != nil {
+							return err
+						}
+						if info, err := fs.Stat(walk[i], dirs[i]); err == nil && !info.IsDir() {
+							return &fs.PathError{Op: "lookup", Path: path.Join(dirs...), Err: errNotDir}
+						}
+					}
+					if !errors.Is(err, fs.ErrNotExist) {
+						// Reading links is denied when the directory
+						// containing them cannot be searched.
+						return permissionError(err, path.Join(dirs...), false)
+					}
+					// A missing directory is reported immediately with the
+					// path that was not found, which may differ from the name
+					// being resolved if links were followed. Some file systems
+					// report synthesized directories as missing in ReadLink,
+					// so the directory is looked up again to confirm.
+					if len(prefix) < len(name) {
+						if err := op(); err != nil {
+							return err
+						}
+						if _, err := fs.Stat(fsys, base); errors.Is(err, fs.ErrNotExist) {
+							return &fs.PathError{Op: "lookup", Path: path.Join(path.Join(dirs...), base), Err: fs.ErrNotExist}
+						}
+					}
 				}
 			}
 
 			if len(prefix) < len(name) {
-				sub, err := fslink.Sub(fsys, base)
-				if err != nil {
+				if err := op(); err != nil {
 					return err
 				}
+				if opts.onDir != nil {
+					if err := opts.onDir(fsys, base); err != nil {
+						return permissionError(err, path.Join(path.Join(dirs...), base), false)
+					}
+				}
+				if opts.accessCheck != nil {
+					var info fs.FileInfo
+					if err := retry(func() (err error) {
+						info, err = fs.Stat(fsys, base)
+						return err
+					}); err != nil {
+						return permissionError(err, path.Join(path.Join(dirs...), base), false)
+					}
+					if err := opts.accessCheck(path.Join(path.Join(dirs...), base), info); err != nil {
+						return err
+					}
+				}
+				var sub fs.FS
+				err := retry(func() (err error) {
+					sub, err = subDir(fsys, base)
+					return err
+				})
+				if err != nil {
+					return permissionError(err, path.Join(path.Join(dirs...), base), false)
+				}
 				walk = append(walk, fsys)
+				dirs = append(dirs, base)
 				fsys = sub
 			}
 
 			return nil
+		}
+
+		err := Walk(name, func(prefix string) error {
+			if opts.recorder == nil {
+				return visit(prefix)
+			}
+			decision = Decision{Path: path.Join(path.Join(dirs...), path.Base(prefix))}
+			err := visit(prefix)
+			if err != nil && err != symlink && !decision.Link {
+				decision.Err = err
+			}
+			opts.recorder.record(decision)
+			return err
 		})
 
+		if p != nil {
+			p.cancel()
+		}
 		if err != symlink {
-			return fsys, path.Base(name), err
+			return resolution{fsys: fsys, base: path.Base(name), dir: path.Join(dirs...), atRoot: atRoot, links: len(chain), link: finalLink, walk: walk}, err
 		}
 	}
 }
@@ -173,34 +909,258 @@ func Walk(name string, fn func(path string) error) error {
 	}
 }
 
+// Prefixes returns the list of path prefixes of name that Walk would pass to
+// its callback function, for example:
+//
+//	Prefixes("a/b/c") => ["a", "a/b", "a/b/c"]
+//	Prefixes(".")     => ["."]
+//
+// The prefixes are sub-strings of name, the function only allocates the slice
+// holding them.
+func Prefixes(name string) []string {
+	prefixes := make([]string, 0, strings.Count(name, "/")+1)
+	Walk(name, func(prefix string) error {
+		prefixes = append(prefixes, prefix)
+		return nil
+	})
+	return prefixes
+}
+
+// WalkBytes is like Walk but it operates on a byte slice. The prefixes passed
+// to fn are sub-slices of name, they are only valid until fn returns.
+func WalkBytes(name []byte, fn func(prefix []byte) error) error {
+	seek := 0
+	for {
+		if i := bytes.IndexByte(name[seek:], '/'); i < 0 {
+			return fn(name)
+		} else {
+			seek += i
+		}
+		if err := fn(name[:seek:seek]); err != nil {
+			return err
+		}
+		seek++
+	}
+}
+
+// WalkDisplay is like Walk but the prefixes passed to fn have their segments
+// separated by sep instead of slashes, which is useful to present paths to
+// users, for example:
+//
+//	WalkDisplay("a/b/c", " › ", fn) => "a", "a › b", "a › b › c"
+func WalkDisplay(name, sep string, fn func(display string) error) error {
+	display := make([]byte, 0, len(name)+strings.Count(name, "/")*len(sep))
+	start := 0
+	return Walk(name, func(prefix string) error {
+		if start > 0 {
+			display = append(display, sep...)
+		}
+		display = append(display, prefix[start:]...)
+		start = len(prefix) + 1
+		return fn(string(display))
+	})
+}
+
 // RooFS returns a fs.FS wrapping fsys and using the Lookup function when
 // accesing files (e.g. calling Open, Stat, etc...).
-func RootFS(fsys fs.FS) fs.FS { return rootFS{fsys} }
+//
+// The options configure the resolution of paths like they would when passed
+// to LookupWith.
+func RootFS(fsys fs.FS, opts ...Option) fs.FS {
+	return rootFS{fsys, newOptions(opts)}
+}
+
+// SubFS is like fs.Sub but the returned file system retains the resolution of
+// symbolic links performed by RootFS.
+//
+// The dir argument is resolved first, following links, and the returned file
+// system is positioned on the resolved directory. Links found when accessing
+// files in the returned file system are resolved relative to fsys, which means
+// that they may point above dir but never escape the root of fsys.
+func SubFS(fsys fs.FS, dir string, opts ...Option) (fs.FS, error) {
+	root := rootFS{fsys, newOptions(opts)}
+	r, err := root.opts.resolve(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	info, err := fs.Stat(r.fsys, r.base)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, &fs.PathError{Op: "sub", Path: dir, Err: errNotDir}
+	}
+	return subDir(noSubRootFS{root}, r.path())
+}
+
+// Deadline is like RootFS but the resolution of paths fails with an error
+// wrapping context.DeadlineExceeded once the wall-clock time passes deadline.
+// The deadline is shared by all the operations on the returned file system.
+//
+// This is useful to cap the time spent accessing the file system during a
+// sequence of operations (e.g. serving a request) without threading a context
+// through them. Like with WithContext, the deadline is checked before each call
+// made to the underlying file system, calls that were already started are not
+// interrupted.
+func Deadline(fsys fs.FS, deadline time.Time, opts ...Option) fs.FS {
+	return RootFS(fsys, append(opts[:len(opts):len(opts)], withDeadline(deadline))...)
+}
+
+// RootFSContext is like RootFS but the resolution of paths is aborted when ctx
+// is canceled, in which case methods of the returned file system return an
+// error wrapping the context error. It is equivalent to passing WithContext
+// to RootFS.
+//
+// The context is captured by the file system: it should not be retained
+// beyond the lifetime of ctx (e.g. the request that a handler is serving).
+func RootFSContext(ctx context.Context, fsys fs.FS, opts ...Option) fs.FS {
+	return RootFS(fsys, append(opts[:len(opts):len(opts)], WithContext(ctx))...)
+}
 
-type rootFS struct{ fs.FS }
+type rootFS struct {
+	fs.FS
+	opts *options
+}
 
 func (fsys rootFS) Open(name string) (fs.File, error) {
-	return Open(fsys.FS, name)
+	return lookup(fsys.opts, fsys.FS, name, fs.FS.Open)
 }
 
 func (fsys rootFS) Stat(name string) (fs.FileInfo, error) {
-	return Stat(fsys.FS, name)
+	if fsys.opts.noFollowFinal {
+		return fsys.opts.lstat(fsys.FS, name)
+	}
+	return lookup(fsys.opts, fsys.FS, name, fs.Stat)
 }
 
 func (fsys rootFS) Sub(name string) (fs.FS, error) {
-	return fslink.Sub(noSubRootFS{fsys}, name)
+	return subDir(noSubRootFS{fsys}, name)
 }
 
 func (fsys rootFS) ReadDir(name string) ([]fs.DirEntry, error) {
-	return ReadDir(fsys.FS, name)
+	return lookup(fsys.opts, fsys.FS, name, fs.ReadDir)
 }
 
 func (fsys rootFS) ReadFile(name string) ([]byte, error) {
-	return ReadFile(fsys.FS, name)
+	return lookup(fsys.opts, fsys.FS, name, fs.ReadFile)
 }
 
 func (fsys rootFS) ReadLink(name string) (string, error) {
-	return ReadLink(fsys.FS, name)
+	return fsys.opts.readLink(fsys.FS, name)
+}
+
+func (fsys rootFS) Lstat(name string) (fs.FileInfo, error) {
+	return fsys.opts.lstat(fsys.FS, name)
+}
+
+// Stats returns the counters accumulated by the file system when it was
+// created with the WithStats option, or the zero value otherwise.
+func (fsys rootFS) Stats() Stats {
+	return fsys.opts.stats.load()
+}
+
+// ResetStats sets the counters returned by Stats back to zero.
+func (fsys rootFS) ResetStats() {
+	fsys.opts.stats.reset()
+}
+
+// subDir is like fslink.Sub but when fsys does not implement fs.SubFS, the
+// returned file system forwards calls to ReadLink to fsys as-is. The ReadLink
+// method of file systems returned by fslink.Sub rejects targets which are not
+// clean paths (e.g. "./d"), while the resolution supports them.
+func subDir(fsys fs.FS, dir string) (fs.FS, error) {
+	if _, ok := fsys.(fs.SubFS); ok || dir == "." {
+		return fslink.Sub(fsys, dir)
+	}
+	f, ok := fsys.(fslink.ReadLinkFS)
+	if !ok {
+		return fslink.Sub(fsys, dir)
+	}
+	if !fs.ValidPath(dir) {
+		return nil, &fs.PathError{Op: "sub", Path: dir, Err: fs.ErrInvalid}
+	}
+	return &subLinkFS{f, dir}, nil
+}
+
+type subLinkFS struct {
+	root fslink.ReadLinkFS
+	dir  string
+}
+
+func (fsys *subLinkFS) join(op, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	if name == "." {
+		return fsys.dir, nil
+	}
+	return fsys.dir + "/" + name, nil
+}
+
+// fixErr rewrites the path of errors to be relative to the directory.
+func (fsys *subLinkFS) fixErr(err error) error {
+	if e, ok := err.(*fs.PathError); ok {
+		switch {
+		case e.Path == fsys.dir:
+			e.Path = "."
+		case strings.HasPrefix(e.Path, fsys.dir+"/"):
+			e.Path = e.Path[len(fsys.dir)+1:]
+		}
+	}
+	return err
+}
+
+func (fsys *subLinkFS) Open(name string) (fs.File, error) {
+	full, err := fsys.join("open", name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := fsys.root.Open(full)
+	return f, fsys.fixErr(err)
+}
+
+func (fsys *subLinkFS) Stat(name string) (fs.FileInfo, error) {
+	full, err := fsys.join("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := fs.Stat(fsys.root, full)
+	return info, fsys.fixErr(err)
+}
+
+func (fsys *subLinkFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	full, err := fsys.join("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := fs.ReadDir(fsys.root, full)
+	return entries, fsys.fixErr(err)
+}
+
+func (fsys *subLinkFS) ReadFile(name string) ([]byte, error) {
+	full, err := fsys.join("readfile", name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := fs.ReadFile(fsys.root, full)
+	return data, fsys.fixErr(err)
+}
+
+func (fsys *subLinkFS) ReadLink(name string) (string, error) {
+	full, err := fsys.join("readlink", name)
+	if err != nil {
+		return "", err
+	}
+	link, err := fsys.root.ReadLink(full)
+	return link, fsys.fixErr(err)
+}
+
+func (fsys *subLinkFS) Sub(dir string) (fs.FS, error) {
+	full, err := fsys.join("sub", dir)
+	if err != nil {
+		return nil, err
+	}
+	return subDir(fsys.root, full)
 }
 
 type noSubRootFS struct{ rootFS }
@@ -208,8 +1168,15 @@ type noSubRootFS struct{ rootFS }
 func (noSubRootFS) Sub() {} // wrong signature, does not match fs.SubFS
 
 var (
+	_ fs.StatFS         = (*subLinkFS)(nil)
+	_ fs.ReadDirFS      = (*subLinkFS)(nil)
+	_ fs.ReadFileFS     = (*subLinkFS)(nil)
+	_ fs.SubFS          = (*subLinkFS)(nil)
+	_ fslink.ReadLinkFS = (*subLinkFS)(nil)
+
 	_ fs.StatFS         = rootFS{}
 	_ fs.ReadDirFS      = rootFS{}
 	_ fs.ReadFileFS     = rootFS{}
 	_ fslink.ReadLinkFS = rootFS{}
+	_ ReadLinkFS        = rootFS{}
 )
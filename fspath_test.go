@@ -1,39 +1,47 @@
 package fspath_test
 
 import (
+	"context"
+	"errors"
 	"io/fs"
+	"path"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/stealthrocket/fslink"
 	"github.com/stealthrocket/fspath"
 	"github.com/stealthrocket/fstest"
 )
 
-func TestWalk(t *testing.T) {
-	for _, test := range [...]struct {
-		name string
-		walk []string
-	}{
-		{
-			name: ".",
-			walk: []string{"."},
-		},
+var walkTests = [...]struct {
+	name string
+	walk []string
+}{
+	{
+		name: ".",
+		walk: []string{"."},
+	},
 
-		{
-			name: "a",
-			walk: []string{"a"},
-		},
+	{
+		name: "a",
+		walk: []string{"a"},
+	},
 
-		{
-			name: "a/b",
-			walk: []string{"a", "a/b"},
-		},
+	{
+		name: "a/b",
+		walk: []string{"a", "a/b"},
+	},
 
-		{
-			name: "a/b/c",
-			walk: []string{"a", "a/b", "a/b/c"},
-		},
-	} {
+	{
+		name: "a/b/c",
+		walk: []string{"a", "a/b", "a/b/c"},
+	},
+}
+
+func TestWalk(t *testing.T) {
+	for _, test := range walkTests {
 		var walk []string
 		if err := fspath.Walk(test.name, func(path string) error {
 			walk = append(walk, path)
@@ -47,6 +55,115 @@ func TestWalk(t *testing.T) {
 	}
 }
 
+func TestWalkBytes(t *testing.T) {
+	for _, test := range walkTests {
+		var walk []string
+		if err := fspath.WalkBytes([]byte(test.name), func(path []byte) error {
+			walk = append(walk, string(path))
+			return nil
+		}); err != nil {
+			t.Error(err)
+		}
+		if !reflect.DeepEqual(walk, test.walk) {
+			t.Errorf("mismatch: want=%q got=%q", walk, test.walk)
+		}
+	}
+}
+
+func TestPrefixes(t *testing.T) {
+	for _, test := range walkTests {
+		if prefixes := fspath.Prefixes(test.name); !reflect.DeepEqual(prefixes, test.walk) {
+			t.Errorf("mismatch: want=%q got=%q", test.walk, prefixes)
+		}
+	}
+}
+
+func TestWalkDisplay(t *testing.T) {
+	var walk []string
+	if err := fspath.WalkDisplay("a/b/c", " › ", func(display string) error {
+		walk = append(walk, display)
+		return nil
+	}); err != nil {
+		t.Error(err)
+	}
+	if want := []string{"a", "a › b", "a › b › c"}; !reflect.DeepEqual(walk, want) {
+		t.Errorf("mismatch: want=%q got=%q", want, walk)
+	}
+}
+
+// plainFS hides the ReadLink method of the file system it wraps.
+type plainFS struct{ fs.FS }
+
+func BenchmarkOpen(b *testing.B) {
+	// Directories are declared explicitly so ReadLink reports them as not
+	// being links, like it would on a real file system.
+	fsys := fstest.MapFS{
+		"a":    &fstest.MapFile{Mode: 0644},
+		"link": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("f/g/h")},
+	}
+	for _, name := range []string{"b/c/d/e", "f/g/h/i/j/k/l"} {
+		for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+			fsys[dir] = &fstest.MapFile{Mode: 0755 | fs.ModeDir}
+		}
+		fsys[name] = &fstest.MapFile{Mode: 0644}
+	}
+
+	for _, backend := range []struct {
+		name string
+		fsys fs.FS
+	}{
+		{name: "readlink", fsys: fsys},
+		{name: "plain", fsys: plainFS{fsys}},
+	} {
+		for _, test := range []struct {
+			name string
+			path string
+		}{
+			{name: "depth=1", path: "a"},
+			{name: "depth=4", path: "b/c/d/e"},
+			{name: "depth=7", path: "f/g/h/i/j/k/l"},
+			{name: "link", path: "link/i/j/k/l"},
+		} {
+			b.Run(backend.name+"/"+test.name, func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					f, err := fspath.Open(backend.fsys, test.path)
+					if err != nil {
+						b.Fatal(err)
+					}
+					f.Close()
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkWalk(b *testing.B) {
+	const name = "a/b/c/d/e/f/g/h"
+
+	b.Run("string", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			fspath.Walk(name, func(string) error { return nil })
+		}
+	})
+
+	b.Run("bytes", func(b *testing.B) {
+		b.ReportAllocs()
+		buf := []byte(name)
+		for i := 0; i < b.N; i++ {
+			fspath.WalkBytes(buf, func([]byte) error { return nil })
+		}
+	})
+}
+
+func BenchmarkPrefixes(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		fspath.Prefixes("a/b/c/d/e/f/g/h")
+	}
+}
+
 func TestLookup(t *testing.T) {
 	fsys := fstest.MapFS{
 		"a":   &fstest.MapFile{Mode: 0755 | fs.ModeDir},
@@ -76,3 +193,722 @@ func TestRootFS(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestReadLink(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a":   &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"a/b": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../c")},
+		"a/c": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("b")},
+		"c/d": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+	}
+
+	for _, test := range [...]struct {
+		name string
+		link string
+	}{
+		{name: "a/b", link: "../c"},
+		{name: "a/c", link: "b"},
+	} {
+		link, err := fspath.ReadLink(fsys, test.name)
+		if err != nil {
+			t.Error(err)
+		} else if link != test.link {
+			t.Errorf("%s: wrong link: want=%q got=%q", test.name, test.link, link)
+		}
+	}
+
+	for _, name := range []string{".", "a", "c", "c/d", "a/b/d", "a/c/d"} {
+		_, err := fspath.ReadLink(fsys, name)
+		if !errors.Is(err, fs.ErrInvalid) {
+			t.Errorf("%s: expected fs.ErrInvalid, got %v", name, err)
+		}
+		var pathErr *fs.PathError
+		if !errors.As(err, &pathErr) {
+			t.Errorf("%s: expected *fs.PathError, got %T", name, err)
+		}
+	}
+
+	if _, err := fspath.ReadLink(fsys, "a/x"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected fs.ErrNotExist, got %v", err)
+	}
+}
+
+func TestLookupLoop(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a":   &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"a/b": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("c")},
+		"a/c": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("b")},
+	}
+
+	_, _, err := fspath.Lookup(fsys, "a/b")
+	if !errors.Is(err, fspath.ErrLoop) {
+		t.Fatalf("expected fspath.ErrLoop, got %v", err)
+	}
+
+	var loopErr *fspath.LoopError
+	if !errors.As(err, &loopErr) {
+		t.Fatalf("expected *fspath.LoopError, got %T", err)
+	}
+	if loopErr.Path != "a/b" {
+		t.Errorf("wrong path: %q", loopErr.Path)
+	}
+	if want := []string{"a/b", "a/c"}; !reflect.DeepEqual(loopErr.Chain, want) {
+		t.Errorf("wrong chain: want=%q got=%q", want, loopErr.Chain)
+	}
+}
+
+func TestLookupSelfLoop(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a":     &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("a")},
+		"b/c":   &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("c")},
+		"b/d":   &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../../b/d")},
+		"b/e/f": &fstest.MapFile{Mode: 0644},
+	}
+
+	for _, test := range []struct {
+		name string
+		link string
+	}{
+		{name: "a", link: "a"},
+		{name: "b/c", link: "b/c"},
+		{name: "b/c/x", link: "b/c"},
+		{name: "b/d", link: "b/d"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			_, _, err := fspath.Lookup(fsys, test.name)
+
+			var loopErr *fspath.LoopError
+			if !errors.As(err, &loopErr) {
+				t.Fatalf("expected *fspath.LoopError, got %v", err)
+			}
+			// The loop is detected on the first link, without following it.
+			if want := []string{test.link}; !reflect.DeepEqual(loopErr.Chain, want) {
+				t.Errorf("wrong chain: want=%q got=%q", want, loopErr.Chain)
+			}
+		})
+	}
+}
+
+func TestLookupNameLoop(t *testing.T) {
+	main := fstest.MapFS{
+		"a": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("b")},
+		"b": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("a")},
+		"c": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("/main/d")},
+		"d": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("/main/c")},
+		"e": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("/other/f")},
+	}
+	other := fstest.MapFS{
+		"f": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("/main/e")},
+	}
+	resolver := fspath.WithAbsoluteLinkResolver(func(target string) (fs.FS, string, error) {
+		if rel, ok := strings.CutPrefix(target, "/main/"); ok {
+			return main, rel, nil
+		}
+		return other, strings.TrimPrefix(target, "/other/"), nil
+	})
+
+	for _, test := range []struct {
+		name  string
+		chain []string
+	}{
+		{name: "a", chain: []string{"a", "b"}},
+		{name: "b/x", chain: []string{"b", "a"}},
+		{name: "c", chain: []string{"c", "d"}},
+		{name: "e", chain: []string{"e", "f"}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			_, _, err := fspath.LookupWith(main, test.name, resolver)
+
+			var loopErr *fspath.LoopError
+			if !errors.As(err, &loopErr) {
+				t.Fatalf("expected *fspath.LoopError, got %v", err)
+			}
+			// The loop is detected when the cycle closes, not after
+			// exhausting the maximum number of links.
+			if !reflect.DeepEqual(loopErr.Chain, test.chain) {
+				t.Errorf("wrong chain: want=%q got=%q", test.chain, loopErr.Chain)
+			}
+		})
+	}
+}
+
+func TestLstat(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a":   &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"a/b": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../c")},
+		"c/d": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+	}
+
+	for _, test := range [...]struct {
+		name string
+		mode fs.FileMode
+	}{
+		{name: "a", mode: fs.ModeDir},
+		{name: "a/b", mode: fs.ModeSymlink},
+		{name: "a/b/d", mode: 0},
+		{name: "c/d", mode: 0},
+	} {
+		info, err := fspath.Lstat(fsys, test.name)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if info.Mode().Type() != test.mode {
+			t.Errorf("%s: wrong file type: want=%v got=%v", test.name, test.mode, info.Mode().Type())
+		}
+	}
+}
+
+func TestRootFSReadLinkFS(t *testing.T) {
+	fsys := fspath.RootFS(fstest.MapFS{
+		"a":   &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"a/b": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../c")},
+		"c/d": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+	}).(fspath.ReadLinkFS)
+
+	link, err := fsys.ReadLink("a/b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if link != "../c" {
+		t.Errorf("wrong link: %q", link)
+	}
+
+	info, err := fsys.Lstat("a/b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Type() != fs.ModeSymlink {
+		t.Errorf("wrong file type: %v", info.Mode().Type())
+	}
+
+	info, err = fs.Stat(fsys, "a/b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.IsDir() {
+		t.Errorf("wrong file type: %v", info.Mode().Type())
+	}
+}
+
+func TestLookupNotDir(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a":   &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"a/b": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../c/d")},
+		"c/d": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+	}
+
+	for _, name := range []string{"c/d/e", "c/d/e/f", "a/b/e"} {
+		_, _, err := fspath.Lookup(fsys, name)
+		if !errors.Is(err, fs.ErrInvalid) {
+			t.Errorf("%s: expected fs.ErrInvalid, got %v", name, err)
+			continue
+		}
+		var pathErr *fs.PathError
+		if !errors.As(err, &pathErr) {
+			t.Errorf("%s: expected *fs.PathError, got %T", name, err)
+		} else if pathErr.Path != "c/d" {
+			t.Errorf("%s: wrong path: %q", name, pathErr.Path)
+		}
+	}
+
+	if _, err := fspath.Stat(fsys, "c/e"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected fs.ErrNotExist, got %v", err)
+	}
+}
+
+func TestLookupDotTargets(t *testing.T) {
+	for _, test := range [...]struct {
+		link string
+		path string
+	}{
+		{link: "./d", path: "x/d"},
+		{link: "./../c/d", path: "c/d"},
+		{link: "e/./f", path: "x/e/f"},
+		{link: "e/../d", path: "x/d"},
+		{link: "e/../../c/d", path: "c/d"},
+		{link: "../x/../c/d", path: "c/d"},
+		{link: "../../x/./e/../d", path: "x/d"},
+		{link: "e/f/../../../../c/d", path: "c/d"},
+	} {
+		fsys := fstest.MapFS{
+			"x/l":   &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte(test.link)},
+			"x/d":   &fstest.MapFile{Mode: 0644, Data: []byte("x/d")},
+			"x/e/f": &fstest.MapFile{Mode: 0644, Data: []byte("x/e/f")},
+			"c/d":   &fstest.MapFile{Mode: 0644, Data: []byte("c/d")},
+		}
+
+		b, err := fspath.ReadFile(fsys, "x/l")
+		if err != nil {
+			t.Errorf("%s: %v", test.link, err)
+		} else if string(b) != test.path {
+			t.Errorf("%s: wrong file: want=%q got=%q", test.link, test.path, b)
+		}
+	}
+}
+
+func TestLookupDotSlashTarget(t *testing.T) {
+	// Targets prefixed with "./" are relative to the directory of the link, a
+	// file with the same name at the root must not be picked instead.
+	fsys := fstest.MapFS{
+		"l":     &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("./d")},
+		"d":     &fstest.MapFile{Mode: 0644, Data: []byte("d")},
+		"a/b/l": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("./d")},
+		"a/b/d": &fstest.MapFile{Mode: 0644, Data: []byte("a/b/d")},
+		"a/b/m": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("././l")},
+	}
+
+	for _, test := range [...]struct {
+		name string
+		path string
+	}{
+		{name: "l", path: "d"},
+		{name: "a/b/l", path: "a/b/d"},
+		{name: "a/b/m", path: "a/b/d"},
+	} {
+		r, err := fspath.Resolve(fsys, test.name)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if r.Path != test.path {
+			t.Errorf("%s: wrong path: want=%q got=%q", test.name, test.path, r.Path)
+		}
+		b, err := fs.ReadFile(r.FS, r.Base)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		} else if string(b) != test.path {
+			t.Errorf("%s: wrong file: want=%q got=%q", test.name, test.path, b)
+		}
+	}
+
+	// The links themselves are read and reported as links, both by the
+	// package functions and by the methods of RootFS.
+	root := fspath.RootFS(fsys).(interface {
+		fs.FS
+		ReadLink(string) (string, error)
+		Lstat(string) (fs.FileInfo, error)
+	})
+	for _, test := range [...]struct {
+		name string
+		link string
+	}{
+		{name: "l", link: "./d"},
+		{name: "a/b/l", link: "./d"},
+		{name: "a/b/m", link: "././l"},
+	} {
+		for _, readLink := range []func(string) (string, error){
+			func(name string) (string, error) { return fspath.ReadLink(fsys, name) },
+			func(name string) (string, error) { return fspath.ReadLink(root, name) },
+			root.ReadLink,
+		} {
+			link, err := readLink(test.name)
+			if err != nil {
+				t.Errorf("%s: %v", test.name, err)
+			} else if link != test.link {
+				t.Errorf("%s: wrong link: want=%q got=%q", test.name, test.link, link)
+			}
+		}
+		for _, lstat := range []func(string) (fs.FileInfo, error){
+			func(name string) (fs.FileInfo, error) { return fspath.Lstat(fsys, name) },
+			func(name string) (fs.FileInfo, error) { return fspath.Lstat(root, name) },
+			root.Lstat,
+		} {
+			info, err := lstat(test.name)
+			if err != nil {
+				t.Errorf("%s: %v", test.name, err)
+			} else if info.Mode().Type() != fs.ModeSymlink {
+				t.Errorf("%s: expected a symbolic link, got %v", test.name, info.Mode())
+			}
+		}
+	}
+}
+
+func TestLookupMalformedTargets(t *testing.T) {
+	for _, test := range [...]struct {
+		link string
+		want string // well-formed equivalent of link
+	}{
+		{link: "c//d", want: "c/d"},
+		{link: "c/d/", want: "c/d"},
+		{link: "c/d//", want: "c/d"},
+		{link: "./c/d", want: "c/d"},
+		{link: ".//c/./d", want: "c/d"},
+		{link: "c/x/../d", want: "c/d"},
+		{link: "..//..", want: "../.."},
+		{link: "../../", want: "../.."},
+		{link: "./../../b/", want: "../../b"},
+		{link: ".//", want: "."},
+		{link: "..//../../e", want: "../../../e"},
+	} {
+		for _, backend := range []struct {
+			name string
+			fsys func(fstest.MapFS) fs.FS
+		}{
+			{name: "sub", fsys: func(fsys fstest.MapFS) fs.FS { return fsys }},
+			{name: "nosub", fsys: func(fsys fstest.MapFS) fs.FS { return &countFS{fsys: fsys} }},
+		} {
+			t.Run(backend.name+"/"+test.link, func(t *testing.T) {
+				resolve := func(link string) (fspath.ResolveResult, error) {
+					return fspath.Resolve(backend.fsys(fstest.MapFS{
+						"a/b/l":   &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte(link)},
+						"a/b/c/d": &fstest.MapFile{Mode: 0644},
+						"a/e":     &fstest.MapFile{Mode: 0644},
+						"e":       &fstest.MapFile{Mode: 0644},
+					}), "a/b/l")
+				}
+				want, err := resolve(test.want)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, err := resolve(test.link)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got.Path != want.Path || got.AtRoot != want.AtRoot {
+					t.Errorf("malformed target resolved differently: want=%q got=%q", want.Path, got.Path)
+				}
+			})
+		}
+	}
+}
+
+func TestSubFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a":   &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"a/b": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../c")},
+		"a/e": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../../../c/d")},
+		"c/d": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+	}
+
+	for _, test := range [...]struct {
+		dir  string
+		name string
+	}{
+		{dir: "a", name: "b/d"},
+		{dir: "a", name: "e"},
+		{dir: "a/b", name: "d"},
+	} {
+		sub, err := fspath.SubFS(fsys, test.dir)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		b, err := fs.ReadFile(sub, test.name)
+		if err != nil {
+			t.Error(err)
+		} else if string(b) != "Hello World!" {
+			t.Errorf("%s: wrong file content: %q", test.name, b)
+		}
+	}
+
+	if _, err := fspath.SubFS(fsys, "a/b/d"); !errors.Is(err, fs.ErrInvalid) {
+		t.Errorf("expected fs.ErrInvalid, got %v", err)
+	}
+}
+
+func TestLookupFinalLinkChain(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a/x":   &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("y")},
+		"a/y":   &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../b/z")},
+		"b/z":   &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("c/d")},
+		"b/c/d": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+	}
+
+	for _, name := range []string{"a/x", "a/y", "b/z"} {
+		dir, base, err := fspath.Lookup(fsys, name)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if base != "d" {
+			t.Errorf("%s: wrong base name: %q", name, base)
+		}
+		if _, err := fslink.ReadLink(dir, base); !errors.Is(err, fs.ErrInvalid) {
+			t.Errorf("%s: resolved to a symbolic link: %v", name, err)
+		}
+
+		b, err := fspath.ReadFile(fsys, name)
+		if err != nil {
+			t.Error(err)
+		} else if string(b) != "Hello World!" {
+			t.Errorf("%s: wrong file content: %q", name, b)
+		}
+
+		info, err := fspath.Stat(fsys, name)
+		if err != nil {
+			t.Error(err)
+		} else if info.Name() != "d" || !info.Mode().IsRegular() {
+			t.Errorf("%s: wrong file info: name=%q mode=%v", name, info.Name(), info.Mode())
+		}
+	}
+}
+
+func TestLookupBudget(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a/x":   &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("y")},
+		"a/y":   &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../b")},
+		"b/z":   &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("w")},
+		"b/w":   &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("c")},
+		"b/c/d": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+	}
+
+	cwd, base, budget, err := fspath.LookupBudget(fsys, "a/x", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if budget != 1 {
+		t.Errorf("wrong remaining budget: %d", budget)
+	}
+
+	cwd, err = fs.Sub(cwd, base)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, budget, err := fspath.LookupBudget(cwd, "c/d", budget); err != nil {
+		t.Error(err)
+	} else if budget != 1 {
+		t.Errorf("wrong remaining budget: %d", budget)
+	}
+
+	if _, _, _, err := fspath.LookupBudget(cwd, "z/d", budget); !errors.Is(err, fspath.ErrLoop) {
+		t.Errorf("expected fspath.ErrLoop, got %v", err)
+	}
+
+	if _, _, _, err := fspath.LookupBudget(cwd, "z/d", 2); err != nil {
+		t.Error(err)
+	}
+
+	loop := fstest.MapFS{
+		"a": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("a/b")},
+	}
+	if _, _, _, err := fspath.LookupBudget(loop, "a", -1); !errors.Is(err, fs.ErrInvalid) {
+		t.Errorf("expected fs.ErrInvalid, got %v", err)
+	}
+	if _, _, _, err := fspath.LookupBudget(loop, "a", 0); !errors.Is(err, fspath.ErrLoop) {
+		t.Errorf("expected fspath.ErrLoop, got %v", err)
+	}
+}
+
+func TestRootFSContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	fsys := fspath.RootFSContext(ctx, fstest.MapFS{
+		"a":   &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"a/b": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../c")},
+		"c/d": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+	})
+
+	f, err := fsys.Open("a/b/d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	cancel()
+
+	if _, err := fsys.Open("a/b/d"); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if _, err := fs.Stat(fsys, "c/d"); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestDeadline(t *testing.T) {
+	deadline := time.Now().Add(50 * time.Millisecond)
+	fsys := fspath.Deadline(fstest.MapFS{
+		"a":   &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"a/b": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../c")},
+		"c/d": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+	}, deadline)
+
+	if _, err := fs.ReadFile(fsys, "a/b/d"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.Stat(fsys, "c/d"); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(time.Until(deadline))
+
+	if _, err := fsys.Open("a/b/d"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+	if _, err := fs.Stat(fsys, "c/d"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestLookupInvalidPath(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a/b": &fstest.MapFile{Mode: 0644},
+	}
+
+	for _, test := range [...]struct {
+		name string
+		want string
+	}{
+		{name: "", want: "empty path"},
+		{name: "a//b", want: "empty segment at index 1"},
+		{name: "a/./b", want: `"." segment at index 1`},
+		{name: "a/../b", want: `".." segment at index 1`},
+		{name: "..", want: `".." segment at index 0`},
+		{name: "/a/b", want: "leading slash"},
+		{name: "a/b/", want: "trailing slash"},
+	} {
+		_, _, err := fspath.Lookup(fsys, test.name)
+		if !errors.Is(err, fs.ErrInvalid) {
+			t.Errorf("%q: expected fs.ErrInvalid, got %v", test.name, err)
+			continue
+		}
+		if !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%q: error does not describe the violation: want=%q got=%q", test.name, test.want, err)
+		}
+	}
+}
+
+func TestLookupMissingIntermediate(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a":   &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"a/b": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("e")},
+		"a/e": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../x/y")},
+		"c/d": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+	}
+
+	for _, name := range []string{"a/b/d", "a/b/c/d"} {
+		// The budget of operations guarantees that the error is returned
+		// promptly instead of after exhausting the limit of links.
+		_, _, err := fspath.LookupWith(fsys, name, fspath.WithMaxOps(10))
+		if !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("%s: expected fs.ErrNotExist, got %v", name, err)
+			continue
+		}
+		var pathErr *fs.PathError
+		if !errors.As(err, &pathErr) || pathErr.Path != "x" {
+			t.Errorf("%s: error does not name the missing directory: %v", name, err)
+		}
+	}
+}
+
+// procFS is a stub computing the target of the "self" link dynamically, like
+// /proc/self on Linux.
+type procFS struct {
+	fstest.MapFS
+	pid string
+}
+
+func (fsys procFS) DynamicReadLink(name string) (string, bool, error) {
+	if name == "self" {
+		return fsys.pid, true, nil
+	}
+	return "", false, nil
+}
+
+func TestLookupDynamicLink(t *testing.T) {
+	fsys := procFS{
+		MapFS: fstest.MapFS{
+			"1/status":  &fstest.MapFile{Mode: 0644, Data: []byte("init")},
+			"42/status": &fstest.MapFile{Mode: 0644, Data: []byte("test")},
+			"init":      &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("1")},
+		},
+		pid: "42",
+	}
+
+	for _, test := range []struct {
+		name string
+		data string
+	}{
+		{name: "self/status", data: "test"},
+		{name: "init/status", data: "init"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			b, err := fspath.ReadFile(fsys, test.name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != test.data {
+				t.Errorf("wrong file content: want=%q got=%q", test.data, b)
+			}
+		})
+	}
+
+	fsys.pid = "1"
+	r, err := fspath.Resolve(fsys, "self/status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Path != "1/status" {
+		t.Errorf("wrong resolved path: want=1/status got=%s", r.Path)
+	}
+}
+
+// deniedFS denies traversal of the directories in dirs, and opening the files
+// in files.
+type deniedFS struct {
+	fsys  fstest.MapFS
+	dirs  []string
+	files []string
+}
+
+func (fsys deniedFS) denied(op, name string, list []string) error {
+	for _, denied := range list {
+		if name == denied || strings.HasPrefix(name, denied+"/") {
+			return &fs.PathError{Op: op, Path: name, Err: fs.ErrPermission}
+		}
+	}
+	return nil
+}
+
+func (fsys deniedFS) Open(name string) (fs.File, error) {
+	if err := fsys.denied("open", path.Dir(name), fsys.dirs); err != nil {
+		return nil, err
+	}
+	if err := fsys.denied("open", name, fsys.files); err != nil {
+		return nil, err
+	}
+	return fsys.fsys.Open(name)
+}
+
+func (fsys deniedFS) ReadLink(name string) (string, error) {
+	if err := fsys.denied("readlink", path.Dir(name), fsys.dirs); err != nil {
+		return "", err
+	}
+	return fsys.fsys.ReadLink(name)
+}
+
+func TestLookupPermissionError(t *testing.T) {
+	fsys := deniedFS{
+		fsys: fstest.MapFS{
+			"a/b":      &fstest.MapFile{Mode: 0644},
+			"c/secret": &fstest.MapFile{Mode: 0644},
+			"link":     &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("a/b")},
+		},
+		dirs:  []string{"a"},
+		files: []string{"c/secret"},
+	}
+
+	for _, test := range []struct {
+		name  string
+		path  string
+		final bool
+	}{
+		{name: "a/b", path: "a", final: false},
+		{name: "link", path: "a", final: false},
+		{name: "c/secret", path: "c/secret", final: true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := fspath.Open(fsys, test.name)
+			if !errors.Is(err, fs.ErrPermission) {
+				t.Fatalf("expected fs.ErrPermission, got %v", err)
+			}
+			var permErr *fspath.PermissionError
+			if !errors.As(err, &permErr) {
+				t.Fatalf("expected *fspath.PermissionError, got %T", err)
+			}
+			if permErr.Path != test.path || permErr.Final != test.final {
+				t.Errorf("wrong error: want=%q (final=%t) got=%q (final=%t)", test.path, test.final, permErr.Path, permErr.Final)
+			}
+		})
+	}
+}
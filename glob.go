@@ -0,0 +1,100 @@
+package fspath
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// errGlobStop is used internally to stop expanding a glob pattern early.
+var errGlobStop = errors.New("stop")
+
+// Glob is like fs.Glob but directories are resolved following symbolic links,
+// so patterns can match files in directories reached through links. Like
+// fs.Glob, the final components matched are not followed: a symbolic link is
+// reported as a match even if it is dangling.
+//
+// Errors accessing the file system are ignored, the only possible returned
+// error is path.ErrBadPattern, reporting that the pattern is malformed.
+func Glob(fsys fs.FS, pattern string) ([]string, error) {
+	var matches []string
+	err := glob(fsys, pattern, func(name string) error {
+		matches = append(matches, name)
+		return nil
+	})
+	return matches, err
+}
+
+// GlobFirst is like Glob but it returns only the first match, in lexical
+// order, and stops expanding the pattern as soon as it is found. The function
+// returns an error wrapping fs.ErrNotExist if nothing matches.
+func GlobFirst(fsys fs.FS, pattern string) (string, error) {
+	var match string
+	err := glob(fsys, pattern, func(name string) error {
+		match = name
+		return errGlobStop
+	})
+	switch {
+	case err == errGlobStop:
+		return match, nil
+	case err != nil:
+		return "", err
+	default:
+		return "", &fs.PathError{Op: "glob", Path: pattern, Err: fs.ErrNotExist}
+	}
+}
+
+// glob expands pattern, calling fn for each match in lexical order. The
+// expansion stops if fn returns an error, which is then returned.
+func glob(fsys fs.FS, pattern string, fn func(string) error) error {
+	elems := strings.Split(pattern, "/")
+	for _, elem := range elems {
+		if _, err := path.Match(elem, ""); err != nil {
+			return err
+		}
+	}
+	if !fs.ValidPath(pattern) {
+		return nil
+	}
+	return globDir(fsys, ".", elems, fn)
+}
+
+func globDir(fsys fs.FS, dir string, elems []string, fn func(string) error) error {
+	elem, last := elems[0], len(elems) == 1
+
+	if !hasMeta(elem) {
+		name := path.Join(dir, elem)
+		if last {
+			if _, err := Lstat(fsys, name); err != nil {
+				return nil
+			}
+			return fn(name)
+		}
+		return globDir(fsys, name, elems[1:], fn)
+	}
+
+	entries, err := ReadDir(fsys, dir)
+	if err != nil {
+		return nil
+	}
+	for _, entry := range entries {
+		if ok, _ := path.Match(elem, entry.Name()); !ok {
+			continue
+		}
+		name := path.Join(dir, entry.Name())
+		if last {
+			err = fn(name)
+		} else {
+			err = globDir(fsys, name, elems[1:], fn)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func hasMeta(elem string) bool {
+	return strings.ContainsAny(elem, `*?[\`)
+}
@@ -0,0 +1,71 @@
+package fspath_test
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+	"reflect"
+	"testing"
+
+	"github.com/stealthrocket/fspath"
+	"github.com/stealthrocket/fstest"
+)
+
+func TestGlob(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a/x.txt":    &fstest.MapFile{Mode: 0644},
+		"a/y.txt":    &fstest.MapFile{Mode: 0644},
+		"a/z.md":     &fstest.MapFile{Mode: 0644},
+		"b":          &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("c")},
+		"c/w.txt":    &fstest.MapFile{Mode: 0644},
+		"c/dangling": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("missing")},
+	}
+
+	for _, test := range []struct {
+		pattern string
+		matches []string
+	}{
+		{pattern: "*/*.txt", matches: []string{"a/x.txt", "a/y.txt", "b/w.txt", "c/w.txt"}},
+		{pattern: "b/*", matches: []string{"b/dangling", "b/w.txt"}},
+		{pattern: "b/dangling", matches: []string{"b/dangling"}},
+		{pattern: "a/*.go", matches: nil},
+	} {
+		t.Run(test.pattern, func(t *testing.T) {
+			matches, err := fspath.Glob(fsys, test.pattern)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(matches, test.matches) {
+				t.Errorf("wrong matches:\nwant=%q\ngot= %q", test.matches, matches)
+			}
+		})
+	}
+
+	if _, err := fspath.Glob(fsys, "a/[x"); !errors.Is(err, path.ErrBadPattern) {
+		t.Errorf("expected path.ErrBadPattern, got %v", err)
+	}
+}
+
+func TestGlobFirst(t *testing.T) {
+	fsys := &countFS{fsys: fstest.MapFS{
+		"a/match": &fstest.MapFile{Mode: 0644},
+		"b/match": &fstest.MapFile{Mode: 0644},
+		"c/match": &fstest.MapFile{Mode: 0644},
+	}}
+
+	match, err := fspath.GlobFirst(fsys, "*/*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if match != "a/match" {
+		t.Errorf("wrong match: want=a/match got=%s", match)
+	}
+	// The other directories are not read once the first match was found.
+	if want, got := []string{".", "a"}, fsys.calls("readdir"); !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong directories read: want=%q got=%q", want, got)
+	}
+
+	if _, err := fspath.GlobFirst(fsys, "*/none"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected fs.ErrNotExist, got %v", err)
+	}
+}
@@ -0,0 +1,18 @@
+package fspath
+
+import (
+	"io/fs"
+	"net/http"
+)
+
+// HTTPDir returns a http.FileSystem serving the files of fsys, resolving the
+// paths of files following symbolic links and clamping them to the root of
+// fsys like RootFS does, so it can be passed to http.FileServer to serve a
+// sandboxed file system. The stock http.FS function passes paths to fsys as-is,
+// which does not follow links on file systems implementing ReadLinkFS.
+//
+// The options configure the resolution of paths like they would when passed
+// to LookupWith.
+func HTTPDir(fsys fs.FS, opts ...Option) http.FileSystem {
+	return http.FS(RootFS(fsys, opts...))
+}
@@ -0,0 +1,63 @@
+package fspath_test
+
+import (
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stealthrocket/fspath"
+	"github.com/stealthrocket/fstest"
+)
+
+func TestHTTPDir(t *testing.T) {
+	fsys := fstest.MapFS{
+		"www/index.html": &fstest.MapFile{Mode: 0644, Data: []byte("index")},
+		"www/static":     &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../assets")},
+		"www/passwd":     &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../../../etc/passwd")},
+		"assets/app.css": &fstest.MapFile{Mode: 0644, Data: []byte("body {}")},
+	}
+	sub, err := fsys.Sub("www")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.FileServer(fspath.HTTPDir(fsys)))
+	defer server.Close()
+
+	for _, test := range []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{path: "/www/index.html", status: http.StatusOK, body: "index"},
+		{path: "/www/static/app.css", status: http.StatusOK, body: "body {}"},
+		{path: "/www/passwd", status: http.StatusNotFound},
+		{path: "/www/missing", status: http.StatusNotFound},
+	} {
+		t.Run(test.path, func(t *testing.T) {
+			res, err := http.Get(server.URL + test.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			b, err := io.ReadAll(res.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.StatusCode != test.status {
+				t.Fatalf("wrong status: want=%d got=%d", test.status, res.StatusCode)
+			}
+			if test.body != "" && string(b) != test.body {
+				t.Errorf("wrong body: want=%q got=%q", test.body, b)
+			}
+		})
+	}
+
+	// Links in the served file system are clamped to its root.
+	f, err := fspath.HTTPDir(sub).Open("/static")
+	if err == nil {
+		f.Close()
+		t.Error("expected an error opening a link escaping the root")
+	}
+}
@@ -0,0 +1,25 @@
+package fspath
+
+import (
+	"errors"
+	"io/fs"
+)
+
+// OpenLazy opens the file at name in fsys, only resolving symbolic links with
+// Lookup if opening the path directly failed with fs.ErrNotExist.
+//
+// This is an optimization for file systems which rarely contain symbolic links
+// and where reading links is expensive; the happy path skips reading each path
+// component as a link, at the cost of a second attempt when it fails.
+//
+// Beware that the function does not offer the same guarantees as Open: when
+// the first attempt succeeds, links were resolved by fsys itself, which may
+// follow them differently (e.g. os.DirFS lets them escape the root). Errors
+// other than fs.ErrNotExist returned by the first attempt are returned as-is.
+func OpenLazy(fsys fs.FS, name string) (fs.File, error) {
+	f, err := fsys.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		f, err = Open(fsys, name)
+	}
+	return f, err
+}
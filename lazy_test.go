@@ -0,0 +1,59 @@
+package fspath_test
+
+import (
+	"io"
+	"io/fs"
+	"testing"
+
+	"github.com/stealthrocket/fspath"
+	"github.com/stealthrocket/fstest"
+)
+
+func TestOpenLazy(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a":   &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"a/b": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../../c")},
+		"c/d": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+	}
+
+	for _, name := range []string{"c/d", "a/b/d"} {
+		f, err := fspath.OpenLazy(fsys, name)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		b, err := io.ReadAll(f)
+		f.Close()
+		if err != nil {
+			t.Error(err)
+		} else if string(b) != "Hello World!" {
+			t.Errorf("%s: wrong file content: %q", name, b)
+		}
+	}
+}
+
+func BenchmarkOpenLazy(b *testing.B) {
+	const name = "a/b/c/d/e/f/g/h"
+	fsys := fstest.MapFS{
+		name: &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+	}
+
+	for _, bench := range []struct {
+		name string
+		open func(fs.FS, string) (fs.File, error)
+	}{
+		{name: "Open", open: fspath.Open},
+		{name: "OpenLazy", open: fspath.OpenLazy},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				f, err := bench.open(fsys, name)
+				if err != nil {
+					b.Fatal(err)
+				}
+				f.Close()
+			}
+		})
+	}
+}
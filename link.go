@@ -0,0 +1,206 @@
+package fspath
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/stealthrocket/fslink"
+)
+
+// LinkTarget returns the relative path to store in a symbolic link located at
+// from so that it points to the file at to.
+//
+// Both from and to must be valid paths relative to the root of the same file
+// system. The returned target is relative to the directory containing from and
+// uses ".." segments to walk up the tree when needed, for example:
+//
+//	LinkTarget("a/b", "c/d") => "../c/d"
+func LinkTarget(from, to string) (string, error) {
+	if !fs.ValidPath(from) || from == "." {
+		return "", &fs.PathError{Op: "linktarget", Path: from, Err: fs.ErrInvalid}
+	}
+	if !fs.ValidPath(to) {
+		return "", &fs.PathError{Op: "linktarget", Path: to, Err: fs.ErrInvalid}
+	}
+
+	dir := splitPath(from)
+	dir = dir[:len(dir)-1]
+	dst := splitPath(to)
+
+	i := 0
+	for i < len(dir) && i < len(dst) && dir[i] == dst[i] {
+		i++
+	}
+
+	elems := make([]string, 0, (len(dir)-i)+(len(dst)-i))
+	for range dir[i:] {
+		elems = append(elems, "..")
+	}
+	elems = append(elems, dst[i:]...)
+
+	if len(elems) == 0 {
+		return ".", nil
+	}
+	return strings.Join(elems, "/"), nil
+}
+
+// LinkTargetKind classifies the targets of symbolic links, see
+// ParseLinkTarget.
+type LinkTargetKind int
+
+const (
+	// LinkTargetInvalid is the kind of targets which are not clean paths.
+	LinkTargetInvalid LinkTargetKind = iota
+	// LinkTargetDot is the kind of the "." target, which refers to the
+	// directory containing the link.
+	LinkTargetDot
+	// LinkTargetRelative is the kind of targets which point to files in the
+	// directory containing the link or one of its sub-directories.
+	LinkTargetRelative
+	// LinkTargetEscaping is the kind of targets starting with ".." segments,
+	// which point outside of the directory containing the link.
+	LinkTargetEscaping
+	// LinkTargetAbsolute is the kind of targets starting with a slash.
+	LinkTargetAbsolute
+)
+
+func (k LinkTargetKind) String() string {
+	switch k {
+	case LinkTargetDot:
+		return "dot"
+	case LinkTargetRelative:
+		return "relative"
+	case LinkTargetEscaping:
+		return "escaping"
+	case LinkTargetAbsolute:
+		return "absolute"
+	default:
+		return "invalid"
+	}
+}
+
+// ParsedLinkTarget is the result of parsing the target of a symbolic link with
+// ParseLinkTarget.
+type ParsedLinkTarget struct {
+	Kind LinkTargetKind
+	// Levels is the number of leading ".." segments of escaping targets.
+	Levels int
+	// Path is the part of the target following the leading ".." segments of
+	// escaping targets, or the leading slash of absolute targets, it is "."
+	// when nothing follows them. For other kinds, it is the target itself.
+	Path string
+}
+
+// ParseLinkTarget classifies the target of a symbolic link, for example:
+//
+//	ParseLinkTarget(".")       => {Kind: LinkTargetDot, Path: "."}
+//	ParseLinkTarget("a/b")     => {Kind: LinkTargetRelative, Path: "a/b"}
+//	ParseLinkTarget("../../x") => {Kind: LinkTargetEscaping, Levels: 2, Path: "x"}
+//	ParseLinkTarget("/abs")    => {Kind: LinkTargetAbsolute, Path: "abs"}
+//
+// The target must be clean according to path.Clean, otherwise the function
+// returns a target of kind LinkTargetInvalid and an error wrapping
+// fs.ErrInvalid. Lookup cleans the targets of links before parsing them, so
+// targets like "a/../x" are followed even though they are reported as invalid
+// by this function.
+func ParseLinkTarget(target string) (ParsedLinkTarget, error) {
+	if target == "" || path.Clean(target) != target {
+		return ParsedLinkTarget{Kind: LinkTargetInvalid, Path: target},
+			&fs.PathError{Op: "parselink", Path: target, Err: fs.ErrInvalid}
+	}
+	switch {
+	case target == ".":
+		return ParsedLinkTarget{Kind: LinkTargetDot, Path: target}, nil
+	case target[0] == '/':
+		rel := target[1:]
+		if rel == "" {
+			rel = "."
+		}
+		return ParsedLinkTarget{Kind: LinkTargetAbsolute, Path: rel}, nil
+	case target == ".." || strings.HasPrefix(target, "../"):
+		levels := 0
+		for target == ".." || strings.HasPrefix(target, "../") {
+			target = strings.TrimPrefix(strings.TrimPrefix(target, ".."), "/")
+			levels++
+		}
+		if target == "" {
+			target = "."
+		}
+		return ParsedLinkTarget{Kind: LinkTargetEscaping, Levels: levels, Path: target}, nil
+	default:
+		return ParsedLinkTarget{Kind: LinkTargetRelative, Path: target}, nil
+	}
+}
+
+func splitPath(name string) []string {
+	if name == "." {
+		return nil
+	}
+	return strings.Split(name, "/")
+}
+
+// ListLinks returns the targets of the symbolic links found in dir, indexed by
+// the name of each link. The dir argument is resolved following links, but the
+// links found in the directory are not followed, and sub-directories are not
+// traversed.
+func ListLinks(fsys fs.FS, dir string) (map[string]string, error) {
+	sub, err := Sub(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := fs.ReadDir(sub, ".")
+	if err != nil {
+		return nil, err
+	}
+	links := make(map[string]string)
+	for _, entry := range entries {
+		if entry.Type() != fs.ModeSymlink {
+			continue
+		}
+		link, err := fslink.ReadLink(sub, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		links[entry.Name()] = link
+	}
+	return links, nil
+}
+
+// LinkTargets is like ListLinks but it returns the canonical paths of the files
+// that the links found in dir resolve to, following chains of links and
+// clamping targets pointing above the root.
+//
+// When links are dangling or cannot be resolved, the function still returns the
+// other targets, and the problems are reported in the returned error, joining
+// a *LinkError for each failed link. Dangling links are mapped to the path of
+// the file that they would resolve to if it existed.
+func LinkTargets(fsys fs.FS, dir string) (map[string]string, error) {
+	r, err := defaultOptions.resolve(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	dir = r.path()
+
+	links, err := ListLinks(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var errs []error
+	targets := make(map[string]string, len(links))
+	for name, link := range links {
+		name = path.Join(dir, name)
+		r, err := defaultOptions.resolve(fsys, name)
+		if err != nil {
+			errs = append(errs, &LinkError{Path: name, Link: link, Err: err})
+			continue
+		}
+		targets[path.Base(name)] = r.path()
+		if _, err := fs.Stat(r.fsys, r.base); err != nil {
+			errs = append(errs, &LinkError{Path: name, Link: link, Err: err})
+		}
+	}
+	return targets, errors.Join(errs...)
+}
@@ -0,0 +1,144 @@
+package fspath_test
+
+import (
+	"errors"
+	"io/fs"
+	"reflect"
+	"testing"
+
+	"github.com/stealthrocket/fspath"
+	"github.com/stealthrocket/fstest"
+)
+
+func TestLinkTarget(t *testing.T) {
+	for _, test := range [...]struct {
+		from string
+		to   string
+		link string
+	}{
+		{from: "a", to: "b", link: "b"},
+		{from: "a/b", to: "c/d", link: "../c/d"},
+		{from: "a/b", to: "a/c", link: "c"},
+		{from: "a/b", to: "a/c/d", link: "c/d"},
+		{from: "a/b/c", to: "a/d", link: "../d"},
+		{from: "a/b/c", to: "x", link: "../../x"},
+	} {
+		link, err := fspath.LinkTarget(test.from, test.to)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if link != test.link {
+			t.Errorf("%s -> %s: want=%q got=%q", test.from, test.to, test.link, link)
+		}
+
+		fsys := fstest.MapFS{
+			test.from: &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte(link)},
+			test.to:   &fstest.MapFile{Mode: 0644, Data: []byte(test.to)},
+		}
+
+		b, err := fspath.ReadFile(fsys, test.from)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if string(b) != test.to {
+			t.Errorf("%s -> %s: wrong file content: %q", test.from, test.to, b)
+		}
+	}
+}
+
+func TestLinkTargetInvalid(t *testing.T) {
+	for _, test := range [...]struct {
+		from string
+		to   string
+	}{
+		{from: ".", to: "a"},
+		{from: "/a", to: "b"},
+		{from: "a", to: "../b"},
+	} {
+		if _, err := fspath.LinkTarget(test.from, test.to); err == nil {
+			t.Errorf("%s -> %s: expected an error", test.from, test.to)
+		}
+	}
+}
+
+func TestListLinks(t *testing.T) {
+	fsys := fstest.MapFS{
+		"x":     &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("a")},
+		"a/b":   &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../c")},
+		"a/d":   &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+		"a/e":   &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("d")},
+		"a/f/g": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../d")},
+		"c/d":   &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+	}
+
+	links, err := fspath.ListLinks(fsys, "x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"b": "../c", "e": "d"}; !reflect.DeepEqual(links, want) {
+		t.Errorf("mismatch: want=%q got=%q", want, links)
+	}
+}
+
+func TestLinkTargets(t *testing.T) {
+	fsys := fstest.MapFS{
+		"x":   &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("a")},
+		"a/b": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../c/d")},
+		"a/e": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("f")},
+		"a/f": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../../../g/d")},
+		"a/h": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("missing")},
+		"c/d": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+		"g":   &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("c")},
+	}
+
+	targets, err := fspath.LinkTargets(fsys, "x")
+	want := map[string]string{
+		"b": "c/d",
+		"e": "c/d",
+		"f": "c/d",
+		"h": "a/missing",
+	}
+	if !reflect.DeepEqual(targets, want) {
+		t.Errorf("mismatch: want=%q got=%q", want, targets)
+	}
+
+	var linkErr *fspath.LinkError
+	if !errors.As(err, &linkErr) {
+		t.Fatalf("expected a *LinkError, got %v", err)
+	}
+	if linkErr.Path != "a/h" || !errors.Is(linkErr, fs.ErrNotExist) {
+		t.Errorf("wrong error for the dangling link: %v", linkErr)
+	}
+}
+
+func TestParseLinkTarget(t *testing.T) {
+	for _, test := range []struct {
+		target string
+		want   fspath.ParsedLinkTarget
+	}{
+		{target: ".", want: fspath.ParsedLinkTarget{Kind: fspath.LinkTargetDot, Path: "."}},
+		{target: "a/b", want: fspath.ParsedLinkTarget{Kind: fspath.LinkTargetRelative, Path: "a/b"}},
+		{target: "..", want: fspath.ParsedLinkTarget{Kind: fspath.LinkTargetEscaping, Levels: 1, Path: "."}},
+		{target: "../../x", want: fspath.ParsedLinkTarget{Kind: fspath.LinkTargetEscaping, Levels: 2, Path: "x"}},
+		{target: "/abs", want: fspath.ParsedLinkTarget{Kind: fspath.LinkTargetAbsolute, Path: "abs"}},
+		{target: "/", want: fspath.ParsedLinkTarget{Kind: fspath.LinkTargetAbsolute, Path: "."}},
+		{target: "a/../x", want: fspath.ParsedLinkTarget{Kind: fspath.LinkTargetInvalid, Path: "a/../x"}},
+		{target: "", want: fspath.ParsedLinkTarget{Kind: fspath.LinkTargetInvalid}},
+	} {
+		t.Run(test.target, func(t *testing.T) {
+			got, err := fspath.ParseLinkTarget(test.target)
+			if test.want.Kind == fspath.LinkTargetInvalid {
+				if !errors.Is(err, fs.ErrInvalid) {
+					t.Errorf("expected fs.ErrInvalid, got %v", err)
+				}
+			} else if err != nil {
+				t.Error(err)
+			}
+			if got != test.want {
+				t.Errorf("wrong result: want=%+v got=%+v", test.want, got)
+			}
+		})
+	}
+}
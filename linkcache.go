@@ -0,0 +1,81 @@
+package fspath
+
+import (
+	"sync"
+	"time"
+)
+
+// WithLinkCache enables caching the path components that were confirmed not to
+// be symbolic links, which saves calls to ReadLink when resolving many paths
+// sharing common prefixes (e.g. serving files from a static/ directory) on file
+// systems where links are sparse.
+//
+// Like WithStats, the option is intended to be passed to RootFS or New, so the
+// cache is shared by all the resolutions made with the returned value. Entries
+// are keyed on the identity of the file system that paths are resolved in, the
+// cache is bypassed for file systems which cannot be identified (e.g. structs
+// holding non-comparable values).
+//
+// The cache trades safety for speed: entries are remembered for ttl, and the
+// final component of paths is always probed, but a directory replaced by a
+// symbolic link during that time is not seen as a link until its entry
+// expires. File systems which follow links themselves, like os.DirFS, then
+// follow it when accessing the files that it contains, without clamping it to
+// the root. The cache therefore breaks the confinement of RootFS if the tree
+// is modified, it must only be used with trees which do not change or whose
+// changes are trusted. Symbolic links themselves are never cached, their
+// targets are read on each resolution so changes to links are always observed.
+func WithLinkCache(ttl time.Duration) Option {
+	return func(o *options) { o.linkCache = &linkCache{ttl: ttl} }
+}
+
+// maxLinkCacheEntries bounds the number of entries of a link cache, expired
+// entries are evicted when it is reached, and the cache is reset if none of
+// them had expired.
+const maxLinkCacheEntries = 1 << 14
+
+// linkCacheKey is a path relative to the root of a file system, identified by
+// fsIdentity.
+type linkCacheKey struct {
+	root any
+	name string
+}
+
+// linkCache is a set of paths which are known not to be symbolic links, mapped
+// to the time when the entries expire.
+type linkCache struct {
+	ttl     time.Duration
+	mutex   sync.Mutex
+	entries map[linkCacheKey]time.Time
+}
+
+func (c *linkCache) notLink(key linkCacheKey) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	expires, ok := c.entries[key]
+	if ok && !time.Now().Before(expires) {
+		delete(c.entries, key)
+		ok = false
+	}
+	return ok
+}
+
+func (c *linkCache) add(key linkCacheKey) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	now := time.Now()
+	if len(c.entries) >= maxLinkCacheEntries {
+		for key, expires := range c.entries {
+			if !now.Before(expires) {
+				delete(c.entries, key)
+			}
+		}
+		if len(c.entries) >= maxLinkCacheEntries {
+			c.entries = nil
+		}
+	}
+	if c.entries == nil {
+		c.entries = make(map[linkCacheKey]time.Time)
+	}
+	c.entries[key] = now.Add(c.ttl)
+}
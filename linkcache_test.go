@@ -0,0 +1,158 @@
+package fspath_test
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stealthrocket/fspath"
+	"github.com/stealthrocket/fstest"
+)
+
+func TestWithLinkCache(t *testing.T) {
+	fsys := &countFS{fsys: fstest.MapFS{
+		"static":       &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"static/css":   &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../assets")},
+		"static/index": &fstest.MapFile{Mode: 0644},
+		"assets":       &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"assets/main":  &fstest.MapFile{Mode: 0644},
+	}}
+	r := fspath.New(fspath.WithLinkCache(time.Hour))
+
+	for _, test := range []struct {
+		name      string
+		path      string
+		readLinks int
+	}{
+		{name: "cold", path: "static/index", readLinks: 2},
+		// The final component is always probed.
+		{name: "warm", path: "static/index", readLinks: 1},
+		// Links are not cached, only the components which are not links
+		// are skipped.
+		{name: "link", path: "static/css/main", readLinks: 3},
+		{name: "link again", path: "static/css/main", readLinks: 2},
+	} {
+		fsys.reset()
+		res, err := r.Resolve(fsys, test.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fs.Stat(res.FS, res.Base); err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		}
+		if n := fsys.count("readlink"); n != test.readLinks {
+			t.Errorf("%s: wrong number of calls to ReadLink: want=%d got=%d", test.name, test.readLinks, n)
+		}
+	}
+}
+
+// strictFS is a file system which does not follow symbolic links, opening a
+// path containing a link fails.
+type strictFS struct{ *countFS }
+
+func (fsys strictFS) Open(name string) (fs.File, error) {
+	for dir := name; dir != "."; dir = path.Dir(dir) {
+		if f, ok := fsys.fsys[dir]; ok && f.Mode.Type() == fs.ModeSymlink {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+		}
+	}
+	return fsys.countFS.Open(name)
+}
+
+func TestWithLinkCacheFileSystems(t *testing.T) {
+	plain := fstest.MapFS{
+		"a":   &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"a/b": &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"a/c": &fstest.MapFile{Mode: 0644},
+	}
+	linked := fstest.MapFS{
+		"a":   &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("d")},
+		"a/c": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../b")},
+		"d":   &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"d/b": &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"d/x": &fstest.MapFile{Mode: 0644, Data: []byte("linked")},
+		"b":   &fstest.MapFile{Mode: 0644, Data: []byte("b")},
+	}
+	r := fspath.New(fspath.WithLinkCache(time.Hour))
+
+	if _, err := r.Stat(strictFS{&countFS{fsys: plain}}, "a/b/x"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected fs.ErrNotExist, got %v", err)
+	}
+	// The entries cached for the first file system must not be used when
+	// resolving paths in the second one.
+	b, err := r.ReadFile(strictFS{&countFS{fsys: linked}}, "a/x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "linked" {
+		t.Errorf("wrong file content: %q", b)
+	}
+
+	// The final component is probed even if it was cached as an
+	// intermediate directory.
+	fsys := strictFS{&countFS{fsys: fstest.MapFS{
+		"a":   &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"a/c": &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"b":   &fstest.MapFile{Mode: 0644, Data: []byte("b")},
+	}}}
+	if _, err := r.Stat(fsys, "a/c/x"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected fs.ErrNotExist, got %v", err)
+	}
+	fsys.fsys["a/c"] = &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../b")}
+	if b, err := r.ReadFile(fsys, "a/c"); err != nil || string(b) != "b" {
+		t.Errorf("wrong file content: %q (%v)", b, err)
+	}
+}
+
+func TestWithLinkCacheExpired(t *testing.T) {
+	fsys := &countFS{fsys: fstest.MapFS{
+		"a":   &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"a/b": &fstest.MapFile{Mode: 0644},
+	}}
+	r := fspath.New(fspath.WithLinkCache(0))
+
+	for i := 0; i < 2; i++ {
+		fsys.reset()
+		if _, err := r.Resolve(fsys, "a/b"); err != nil {
+			t.Fatal(err)
+		}
+		if n := fsys.count("readlink"); n != 2 {
+			t.Errorf("wrong number of calls to ReadLink: want=2 got=%d", n)
+		}
+	}
+}
+
+func BenchmarkWithLinkCache(b *testing.B) {
+	files := fstest.MapFS{
+		"static":     &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"static/css": &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+	}
+	names := make([]string, 100)
+	for i := range names {
+		names[i] = fmt.Sprintf("static/css/%d.css", i)
+		files[names[i]] = &fstest.MapFile{Mode: 0644}
+	}
+
+	for _, test := range []struct {
+		name string
+		opts []fspath.Option
+	}{
+		{name: "default"},
+		{name: "cache", opts: []fspath.Option{fspath.WithLinkCache(time.Minute)}},
+	} {
+		b.Run(test.name, func(b *testing.B) {
+			fsys := &countFS{fsys: files}
+			r := fspath.New(test.opts...)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := r.Resolve(fsys, names[i%len(names)]); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(fsys.count("readlink"))/float64(b.N), "readlinks/op")
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package fspath
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+)
+
+// ListResolved returns the paths of all the files reachable under root in fsys,
+// following symbolic links to directories. Directories are not included, and
+// symbolic links are reported if they point to files, but dangling links are
+// omitted.
+//
+// The paths are reported with the names used to reach each file rather than
+// the canonical paths that they resolve to, so callers can open them through
+// fsys. A file reachable through multiple links is therefore listed once for
+// each of those paths. Links pointing to one of the directories containing
+// them are not followed, since it would cause an infinite number of paths to
+// be listed.
+func ListResolved(fsys fs.FS, root string) ([]string, error) {
+	l := &lister{fsys: fsys}
+	if err := l.list(root); err != nil {
+		return nil, err
+	}
+	return l.names, nil
+}
+
+type lister struct {
+	fsys  fs.FS
+	names []string
+	// dirs is the stack of canonical paths of the directories containing the
+	// links being followed.
+	dirs []string
+}
+
+func (l *lister) list(root string) error {
+	r, err := defaultOptions.resolve(l.fsys, root)
+	if err != nil {
+		return err
+	}
+	canonical := r.path()
+
+	return WalkDirFS(l.fsys, root, func(name string, sub fs.FS, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		switch d.Type() {
+		case fs.ModeDir:
+			return nil
+		case fs.ModeSymlink:
+		default:
+			l.names = append(l.names, name)
+			return nil
+		}
+
+		r, err := defaultOptions.resolve(l.fsys, name)
+		if err != nil {
+			return err
+		}
+		info, err := fs.Stat(r.fsys, r.base)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if !info.IsDir() {
+			l.names = append(l.names, name)
+			return nil
+		}
+
+		dir := path.Join(canonical, relPath(path.Dir(name), root))
+		target := r.path()
+		for _, parent := range append(l.dirs, dir) {
+			if hasPathPrefix(parent, target) {
+				return nil
+			}
+		}
+		l.dirs = append(l.dirs, dir)
+		defer func() { l.dirs = l.dirs[:len(l.dirs)-1] }()
+		return l.list(name)
+	})
+}
@@ -0,0 +1,54 @@
+package fspath_test
+
+import (
+	"io/fs"
+	"reflect"
+	"testing"
+
+	"github.com/stealthrocket/fspath"
+	"github.com/stealthrocket/fstest"
+)
+
+func TestListResolved(t *testing.T) {
+	fsys := fstest.MapFS{
+		"src/main.go":     &fstest.MapFile{Mode: 0644},
+		"src/lib":         &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../vendor/lib")},
+		"src/loop":        &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("..")},
+		"src/readme":      &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../README")},
+		"src/dangling":    &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("missing")},
+		"vendor/lib/a.go": &fstest.MapFile{Mode: 0644},
+		"vendor/lib/self": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../lib")},
+		"vendor/lib/src":  &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../../src")},
+		"README":          &fstest.MapFile{Mode: 0644},
+	}
+
+	names, err := fspath.ListResolved(fsys, "src")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"src/lib/a.go",
+		"src/main.go",
+		"src/readme",
+	}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("wrong names:\nwant=%q\ngot= %q", want, names)
+	}
+
+	names, err = fspath.ListResolved(fsys, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []string{
+		"README",
+		"src/lib/a.go",
+		"src/main.go",
+		"src/readme",
+		"vendor/lib/a.go",
+		"vendor/lib/src/main.go",
+		"vendor/lib/src/readme",
+	}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("wrong names:\nwant=%q\ngot= %q", want, names)
+	}
+}
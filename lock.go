@@ -0,0 +1,40 @@
+package fspath
+
+import "io/fs"
+
+// LockFS is the interface implemented by file systems which support advisory
+// locking of files.
+type LockFS interface {
+	fs.FS
+	// Lock acquires a lock on the file at name, which is shared unless
+	// exclusive is true, and returns a function releasing it.
+	Lock(name string, exclusive bool) (unlock func() error, err error)
+}
+
+// OpenLocked opens the file at name in fsys, following symbolic links, and
+// acquires an advisory lock on it. The returned function releases the lock, it
+// must be called before closing the file.
+//
+// The lock is acquired by calling Lock on fsys with the canonical path of the
+// file, which must therefore implement LockFS, otherwise ErrUnsupported is
+// returned.
+func OpenLocked(fsys fs.FS, name string, exclusive bool) (fs.File, func() error, error) {
+	l, ok := fsys.(LockFS)
+	if !ok {
+		return nil, nil, &fs.PathError{Op: "lock", Path: name, Err: ErrUnsupported}
+	}
+	r, err := defaultOptions.resolve(fsys, name)
+	if err != nil {
+		return nil, nil, err
+	}
+	f, err := r.fsys.Open(r.base)
+	if err != nil {
+		return nil, nil, err
+	}
+	unlock, err := l.Lock(r.path(), exclusive)
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	return f, unlock, nil
+}
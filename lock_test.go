@@ -0,0 +1,64 @@
+package fspath_test
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+
+	"github.com/stealthrocket/fspath"
+	"github.com/stealthrocket/fstest"
+)
+
+// lockFS records the locks acquired on files, indexed by name.
+type lockFS struct {
+	fstest.MapFS
+	locks map[string]bool
+}
+
+func (fsys lockFS) Lock(name string, exclusive bool) (func() error, error) {
+	if _, locked := fsys.locks[name]; locked {
+		return nil, &fs.PathError{Op: "lock", Path: name, Err: fs.ErrExist}
+	}
+	fsys.locks[name] = exclusive
+	return func() error {
+		delete(fsys.locks, name)
+		return nil
+	}, nil
+}
+
+func TestOpenLocked(t *testing.T) {
+	fsys := lockFS{
+		MapFS: fstest.MapFS{
+			"a":   &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+			"a/b": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../c")},
+			"c/d": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+		},
+		locks: make(map[string]bool),
+	}
+
+	f, unlock, err := fspath.OpenLocked(fsys, "a/b/d", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if exclusive, locked := fsys.locks["c/d"]; !locked || !exclusive {
+		t.Errorf("expected an exclusive lock on c/d: %v", fsys.locks)
+	}
+	if _, _, err := fspath.OpenLocked(fsys, "c/d", false); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("expected the file to be locked: %v", err)
+	}
+	if err := unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if len(fsys.locks) != 0 {
+		t.Errorf("expected the lock to be released: %v", fsys.locks)
+	}
+}
+
+func TestOpenLockedUnsupported(t *testing.T) {
+	fsys := fstest.MapFS{"a": &fstest.MapFile{Mode: 0644}}
+	if _, _, err := fspath.OpenLocked(fsys, "a", false); !errors.Is(err, fspath.ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported, got %v", err)
+	}
+}
@@ -0,0 +1,55 @@
+package fspath
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+)
+
+// minMmapSize is the size below which OpenMmap reads files instead of mapping
+// them in memory, the cost of setting up the mapping outweighs the copy.
+const minMmapSize = 64 * 1024
+
+// OpenMmap resolves name in fsys following symbolic links, and maps the file in
+// memory as read-only. It returns the content of the file, and a function that
+// must be called to release it when the program does not need it anymore; the
+// content must not be accessed after the function was called.
+//
+// The file is read into memory instead if fsys did not open a file of the
+// operating system (see OpenOSFile), if the file is small, or if memory
+// mapping is not supported on the platform.
+func OpenMmap(fsys fs.FS, name string) ([]byte, func() error, error) {
+	f, err := OpenOSFile(fsys, name)
+	if err != nil {
+		if !errors.Is(err, ErrNotOSFile) {
+			return nil, nil, err
+		}
+		data, err := ReadFile(fsys, name)
+		if err != nil {
+			return nil, nil, err
+		}
+		return data, noUnmap, nil
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+	if size := info.Size(); size >= minMmapSize && int64(int(size)) == size {
+		data, err := mmap(f, int(size))
+		if err == nil {
+			return data, func() error { return munmap(data) }, nil
+		}
+		if !errors.Is(err, ErrUnsupported) {
+			return nil, nil, &fs.PathError{Op: "mmap", Path: name, Err: err}
+		}
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, noUnmap, nil
+}
+
+func noUnmap() error { return nil }
@@ -0,0 +1,55 @@
+package fspath_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stealthrocket/fspath"
+	"github.com/stealthrocket/fstest"
+)
+
+func TestOpenMmap(t *testing.T) {
+	dir := makeDirTree(t)
+	large := bytes.Repeat([]byte("Hello World!\n"), 100000)
+	if err := os.WriteFile(filepath.Join(dir, "c", "large"), large, 0644); err != nil {
+		t.Fatal(err)
+	}
+	fsys := fspath.OSRootFS(dir)
+
+	for _, test := range []struct {
+		name string
+		data []byte
+	}{
+		{name: "a/b/large", data: large},
+		{name: "a/b/d", data: []byte("Hello World!")},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			data, unmap, err := fspath.OpenMmap(fsys, test.name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(data, test.data) {
+				t.Errorf("wrong file content: want=%d bytes got=%d bytes", len(test.data), len(data))
+			}
+			if err := unmap(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestOpenMmapNotOSFile(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+	}
+	data, unmap, err := fspath.OpenMmap(fsys, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unmap()
+	if string(data) != "Hello World!" {
+		t.Errorf("wrong file content: %q", data)
+	}
+}
@@ -0,0 +1,285 @@
+package fspath
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/stealthrocket/fslink"
+)
+
+// Mount returns a file system exposing the content of base, where the tree at
+// the path at is replaced by the content of fsys.
+//
+// Symbolic links crossing the mount point are resolved with the following
+// rules, which mirror how mounts work on posix systems when the mounted tree
+// is used as a root:
+//
+//   - links of base pointing into the at subtree resolve into fsys
+//   - links of fsys pointing above the mount point are clamped to it, they
+//     never resolve into base
+//
+// The files of base located under at are shadowed by the mounted file system.
+func Mount(base fs.FS, at string, fsys fs.FS) (fs.FS, error) {
+	if !fs.ValidPath(at) {
+		return nil, &fs.PathError{Op: "mount", Path: at, Err: fs.ErrInvalid}
+	}
+	if at == "." {
+		return mountedFS{fsys, 0}, nil
+	}
+	return mountFS{base, at, fsys}, nil
+}
+
+// mountFS is a view of the base file system positioned on a directory which
+// contains the mount point.
+type mountFS struct {
+	base fs.FS
+	at   string
+	fs   fs.FS
+}
+
+// route returns the file system that name belongs to and its name in it.
+func (fsys mountFS) route(name string) (fs.FS, string) {
+	switch {
+	case name == fsys.at:
+		return mountedFS{fsys.fs, 0}, "."
+	case strings.HasPrefix(name, fsys.at+"/"):
+		return mountedFS{fsys.fs, 0}, name[len(fsys.at)+1:]
+	default:
+		return fsys.base, name
+	}
+}
+
+func (fsys mountFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	dir, base := fsys.route(name)
+	return dir.Open(base)
+}
+
+func (fsys mountFS) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+	dir, base := fsys.route(name)
+	return fs.Stat(dir, base)
+}
+
+func (fsys mountFS) ReadLink(name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+	if name == fsys.at {
+		// The mount point is always a directory, even if base had a link
+		// at this location.
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+	dir, base := fsys.route(name)
+	return fslink.ReadLink(dir, base)
+}
+
+func (fsys mountFS) Sub(dir string) (fs.FS, error) {
+	if !fs.ValidPath(dir) {
+		return nil, &fs.PathError{Op: "sub", Path: dir, Err: fs.ErrInvalid}
+	}
+	if dir == "." {
+		return fsys, nil
+	}
+	if strings.HasPrefix(fsys.at, dir+"/") {
+		sub, err := fslink.Sub(fsys.base, dir)
+		if err != nil {
+			return nil, err
+		}
+		return mountFS{sub, fsys.at[len(dir)+1:], fsys.fs}, nil
+	}
+	sub, base := fsys.route(dir)
+	return fslink.Sub(sub, base)
+}
+
+// mountedFS is a view of the mounted file system positioned on a directory at
+// the given depth below the mount point.
+type mountedFS struct {
+	fs    fs.FS
+	depth int
+}
+
+func (fsys mountedFS) Open(name string) (fs.File, error) {
+	return fsys.fs.Open(name)
+}
+
+func (fsys mountedFS) Stat(name string) (fs.FileInfo, error) {
+	return fs.Stat(fsys.fs, name)
+}
+
+// ReadLink rewrites the targets of links pointing above the mount point so that
+// they are clamped to it, the resolution never walks back up to the directory
+// containing the mount point.
+func (fsys mountedFS) ReadLink(name string) (string, error) {
+	link, err := fslink.ReadLink(fsys.fs, name)
+	if err != nil {
+		return "", err
+	}
+	depth := fsys.depth + len(splitPath(path.Dir(name)))
+	link = path.Clean(link)
+	elems := splitPath(link)
+	n := 0
+	for n < len(elems) && elems[n] == ".." {
+		n++
+	}
+	if n > depth {
+		elems = elems[n-depth:]
+	}
+	if len(elems) == 0 {
+		return ".", nil
+	}
+	return strings.Join(elems, "/"), nil
+}
+
+func (fsys mountedFS) Sub(dir string) (fs.FS, error) {
+	sub, err := fslink.Sub(fsys.fs, dir)
+	if err != nil {
+		return nil, err
+	}
+	return mountedFS{sub, fsys.depth + len(splitPath(dir))}, nil
+}
+
+// BindFile returns a file system exposing the content of base, where the path
+// at resolves to a read-only copy of file, overriding the file that base may
+// have at this location. The content of file is read when calling BindFile,
+// the file can be closed when the function returns.
+//
+// Symbolic links of base pointing to at resolve to the bound file. Listing the
+// directory containing at returns the entries of base, the bound file is only
+// visible when accessed by name.
+func BindFile(base fs.FS, at string, file fs.File) (fs.FS, error) {
+	if !fs.ValidPath(at) || at == "." {
+		return nil, &fs.PathError{Op: "bind", Path: at, Err: fs.ErrInvalid}
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, &fs.PathError{Op: "bind", Path: at, Err: fs.ErrInvalid}
+	}
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	bound := &boundFile{name: path.Base(at), data: data, info: info}
+	return bindFS{base, at, bound}, nil
+}
+
+// bindFS is a view of the base file system positioned on a directory which
+// contains the bound file.
+type bindFS struct {
+	base fs.FS
+	at   string
+	file *boundFile
+}
+
+func (fsys bindFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	switch {
+	case name == fsys.at:
+		return fsys.file.open(), nil
+	case strings.HasPrefix(name, fsys.at+"/"):
+		return nil, &fs.PathError{Op: "open", Path: name, Err: errNotDir}
+	}
+	return fsys.base.Open(name)
+}
+
+func (fsys bindFS) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+	switch {
+	case name == fsys.at:
+		return fsys.file, nil
+	case strings.HasPrefix(name, fsys.at+"/"):
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: errNotDir}
+	}
+	return fs.Stat(fsys.base, name)
+}
+
+func (fsys bindFS) ReadLink(name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+	switch {
+	case name == fsys.at:
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	case strings.HasPrefix(name, fsys.at+"/"):
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: errNotDir}
+	}
+	return fslink.ReadLink(fsys.base, name)
+}
+
+func (fsys bindFS) Sub(dir string) (fs.FS, error) {
+	if !fs.ValidPath(dir) {
+		return nil, &fs.PathError{Op: "sub", Path: dir, Err: fs.ErrInvalid}
+	}
+	switch {
+	case dir == ".":
+		return fsys, nil
+	case dir == fsys.at || strings.HasPrefix(dir, fsys.at+"/"):
+		return nil, &fs.PathError{Op: "sub", Path: dir, Err: errNotDir}
+	case strings.HasPrefix(fsys.at, dir+"/"):
+		sub, err := fslink.Sub(fsys.base, dir)
+		if err != nil {
+			return nil, err
+		}
+		return bindFS{sub, fsys.at[len(dir)+1:], fsys.file}, nil
+	}
+	return fslink.Sub(fsys.base, dir)
+}
+
+// boundFile holds the content of a file bound with BindFile, it implements
+// fs.FileInfo to describe the file.
+type boundFile struct {
+	name string
+	data []byte
+	info fs.FileInfo
+}
+
+func (f *boundFile) Name() string       { return f.name }
+func (f *boundFile) Size() int64        { return int64(len(f.data)) }
+func (f *boundFile) Mode() fs.FileMode  { return f.info.Mode() &^ 0222 }
+func (f *boundFile) ModTime() time.Time { return f.info.ModTime() }
+func (f *boundFile) IsDir() bool        { return false }
+func (f *boundFile) Sys() any           { return nil }
+
+func (f *boundFile) open() fs.File {
+	return &openBoundFile{Reader: bytes.NewReader(f.data), file: f}
+}
+
+type openBoundFile struct {
+	*bytes.Reader
+	file *boundFile
+}
+
+func (f *openBoundFile) Stat() (fs.FileInfo, error) { return f.file, nil }
+
+func (f *openBoundFile) Close() error { return nil }
+
+var (
+	_ fs.StatFS         = bindFS{}
+	_ fs.SubFS          = bindFS{}
+	_ fslink.ReadLinkFS = bindFS{}
+)
+
+var (
+	_ fs.StatFS         = mountFS{}
+	_ fs.SubFS          = mountFS{}
+	_ fslink.ReadLinkFS = mountFS{}
+
+	_ fs.StatFS         = mountedFS{}
+	_ fs.SubFS          = mountedFS{}
+	_ fslink.ReadLinkFS = mountedFS{}
+)
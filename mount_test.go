@@ -0,0 +1,106 @@
+package fspath_test
+
+import (
+	"io/fs"
+	"testing"
+
+	"github.com/stealthrocket/fspath"
+	"github.com/stealthrocket/fstest"
+)
+
+func TestMount(t *testing.T) {
+	base := fstest.MapFS{
+		"a/b":   &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../m/c")},
+		"a/d":   &fstest.MapFile{Mode: 0644, Data: []byte("base")},
+		"e":     &fstest.MapFile{Mode: 0644, Data: []byte("outside")},
+		"m/c/d": &fstest.MapFile{Mode: 0644, Data: []byte("shadowed")},
+	}
+	mounted := fstest.MapFS{
+		"c/d":   &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+		"c/e":   &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../../../e")},
+		"c/f/g": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../../../../a/d")},
+		"e":     &fstest.MapFile{Mode: 0644, Data: []byte("inside")},
+		"a/d":   &fstest.MapFile{Mode: 0644, Data: []byte("mounted")},
+		"x":     &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("..")},
+	}
+
+	fsys, err := fspath.Mount(base, "m", mounted)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range [...]struct {
+		name string
+		data string
+	}{
+		// Links of the base file system pointing into the mount point
+		// resolve into the mounted file system.
+		{name: "a/b/d", data: "Hello World!"},
+		{name: "m/c/d", data: "Hello World!"},
+		// Links of the mounted file system pointing above the mount point
+		// are clamped to it.
+		{name: "m/c/e", data: "inside"},
+		{name: "a/b/e", data: "inside"},
+		{name: "m/c/f/g", data: "mounted"},
+		{name: "m/x/e", data: "inside"},
+		{name: "a/d", data: "base"},
+		{name: "e", data: "outside"},
+	} {
+		b, err := fspath.ReadFile(fsys, test.name)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if string(b) != test.data {
+			t.Errorf("%s: wrong file content: want=%q got=%q", test.name, test.data, b)
+		}
+	}
+}
+
+func TestBindFile(t *testing.T) {
+	base := fstest.MapFS{
+		"app/config":   &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../etc/app.conf")},
+		"etc/app.conf": &fstest.MapFile{Mode: 0644, Data: []byte("original")},
+		"etc/other":    &fstest.MapFile{Mode: 0644, Data: []byte("other")},
+	}
+	config := fstest.MapFS{
+		"app.conf": &fstest.MapFile{Mode: 0644, Data: []byte("injected")},
+	}
+	f, err := config.Open("app.conf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	fsys, err := fspath.BindFile(base, "etc/app.conf", f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range [...]struct {
+		name string
+		data string
+	}{
+		{name: "etc/app.conf", data: "injected"},
+		{name: "app/config", data: "injected"},
+		{name: "etc/other", data: "other"},
+	} {
+		b, err := fspath.ReadFile(fsys, test.name)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		} else if string(b) != test.data {
+			t.Errorf("%s: wrong file content: want=%q got=%q", test.name, test.data, b)
+		}
+	}
+
+	info, err := fspath.Stat(fsys, "app/config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm()&0222 != 0 {
+		t.Errorf("bound file is writable: %v", info.Mode())
+	}
+	if info.Size() != int64(len("injected")) {
+		t.Errorf("wrong size: %d", info.Size())
+	}
+}
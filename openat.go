@@ -0,0 +1,212 @@
+package fspath
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// OpenAtFS is the interface implemented by file systems which support opening
+// files relative to an open directory, similarly to openat(2), and reading the
+// targets of symbolic links relative to it, similarly to readlinkat(2).
+//
+// The interface is only used by OpenDir and the methods of the Dir handles that
+// it returns. Lookup and the other functions of the package resolve paths by
+// name from the root of the file system whether or not it implements OpenAtFS.
+type OpenAtFS interface {
+	fs.FS
+	OpenAt(dir fs.File, name string) (fs.File, error)
+	ReadLinkAt(dir fs.File, name string) (string, error)
+}
+
+// Dir is a handle to a directory opened by OpenDir.
+//
+// When the file system implements OpenAtFS, the paths passed to Open are
+// resolved relative to the directory handle: each path component is opened
+// with OpenAt, and symbolic links are read with ReadLinkAt from the handle of
+// the directory containing them, so renaming or replacing the directory or its
+// parents does not affect the resolution. The parents of the directory are
+// not held open, links walking above the directory with ".." segments are
+// resolved from the root of the file system instead.
+type Dir struct {
+	fsys fs.FS
+	file fs.File
+	path string
+}
+
+// OpenDir opens the directory at name in fsys, following symbolic links. The
+// returned handle must be closed when the caller does not need it anymore.
+//
+// If fsys implements OpenAtFS, name is resolved relative to a handle to the
+// root of fsys, one component at a time.
+func OpenDir(fsys fs.FS, name string) (*Dir, error) {
+	if err := checkPath(name); err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+
+	if at, ok := fsys.(OpenAtFS); ok {
+		w := &atWalker{fsys: at, origin: name}
+		f, dir, err := w.walkRoot(name)
+		if err != nil {
+			return nil, err
+		}
+		return newDir(fsys, f, name, dir)
+	}
+
+	r, err := defaultOptions.resolve(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := r.fsys.Open(r.base)
+	if err != nil {
+		return nil, err
+	}
+	return newDir(fsys, f, name, r.path())
+}
+
+func newDir(fsys fs.FS, f fs.File, name, dir string) (*Dir, error) {
+	info, err := f.Stat()
+	if err == nil && !info.IsDir() {
+		err = &fs.PathError{Op: "open", Path: name, Err: errNotDir}
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &Dir{fsys: fsys, file: f, path: dir}, nil
+}
+
+// Path returns the canonical path of the directory at the time it was opened.
+func (d *Dir) Path() string { return d.path }
+
+// Close closes the directory handle.
+func (d *Dir) Close() error { return d.file.Close() }
+
+// Open opens the file at name relative to the directory, following symbolic
+// links. Links are resolved like they would be by Lookup, with ".." segments
+// clamped to the root of the file system.
+//
+// If the file system does not implement OpenAtFS, name is resolved from the
+// root of the file system by joining it to the path of the directory, which
+// offers no protection against concurrent modifications of the tree.
+func (d *Dir) Open(name string) (fs.File, error) {
+	if err := checkPath(name); err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	at, ok := d.fsys.(OpenAtFS)
+	if !ok {
+		return lookup(defaultOptions, d.fsys, path.Join(d.path, name), fs.FS.Open)
+	}
+	w := &atWalker{fsys: at, origin: name}
+	f, _, err := w.walk(d.file, d.path, name)
+	return f, err
+}
+
+// atWalker resolves paths relative to directory handles with the methods of
+// OpenAtFS.
+type atWalker struct {
+	fsys   OpenAtFS
+	origin string
+	chain  []string
+}
+
+// walkRoot resolves name relative to a handle to the root of the file system.
+func (w *atWalker) walkRoot(name string) (fs.File, string, error) {
+	root, err := w.fsys.Open(".")
+	if err != nil {
+		return nil, "", permissionError(err, ".", name == ".")
+	}
+	defer root.Close()
+	for name == ".." || strings.HasPrefix(name, "../") {
+		name = trimDotDot(name)
+	}
+	return w.walk(root, ".", name)
+}
+
+// trimDotDot removes the leading ".." segment of name, returning "." if
+// nothing follows it.
+func trimDotDot(name string) string {
+	if name = strings.TrimPrefix(strings.TrimPrefix(name, ".."), "/"); name == "" {
+		name = "."
+	}
+	return name
+}
+
+// walk resolves name relative to the handle base of the directory at the
+// canonical path dir, and returns the opened file and its canonical path. The
+// base handle is not closed.
+func (w *atWalker) walk(base fs.File, dir, name string) (fs.File, string, error) {
+	// The stack holds the handles of the directories opened while walking
+	// down from base, and dirs their names.
+	stack := []fs.File{base}
+	dirs := []string{}
+	defer func() {
+		for _, f := range stack[1:] {
+			f.Close()
+		}
+	}()
+
+	for name != "." {
+		elem, rest, more := strings.Cut(name, "/")
+		top := stack[len(stack)-1]
+		current := path.Join(dir, path.Join(dirs...), elem)
+
+		link, err := w.fsys.ReadLinkAt(top, elem)
+		switch {
+		case err == nil:
+		case errors.Is(err, fs.ErrInvalid), errors.Is(err, fs.ErrNotExist):
+			// Not a link, or missing in which case OpenAt reports it.
+			next, err := w.fsys.OpenAt(top, elem)
+			if err != nil {
+				return nil, "", permissionError(err, current, !more)
+			}
+			if !more {
+				return next, current, nil
+			}
+			stack = append(stack, next)
+			dirs = append(dirs, elem)
+			name = rest
+			continue
+		default:
+			// Reading links is denied when the directory containing them
+			// cannot be searched.
+			return nil, "", permissionError(err, path.Join(dir, path.Join(dirs...)), false)
+		}
+
+		if len(w.chain) >= defaultOptions.maxLinks {
+			return nil, "", &LoopError{Path: w.origin, Chain: w.chain}
+		}
+		w.chain = append(w.chain, current)
+
+		// Absolute targets are not followed, like in Lookup.
+		link = path.Clean(link)
+		if strings.HasPrefix(link, "/") {
+			return nil, "", &fs.PathError{Op: "lookup", Path: link, Err: fs.ErrNotExist}
+		}
+		name = path.Join(link, rest)
+		for name == ".." || strings.HasPrefix(name, "../") {
+			if len(stack) == 1 {
+				if dir == "." {
+					// Above the root, the ".." segments are dropped.
+					name = trimDotDot(name)
+					continue
+				}
+				// No handles are held for the parents of base, the rest
+				// of the path is resolved from the root.
+				return w.walkRoot(path.Join(path.Dir(dir), trimDotDot(name)))
+			}
+			stack[len(stack)-1].Close()
+			stack = stack[:len(stack)-1]
+			dirs = dirs[:len(dirs)-1]
+			name = trimDotDot(name)
+		}
+	}
+
+	current := path.Join(dir, path.Join(dirs...))
+	f, err := w.fsys.OpenAt(stack[len(stack)-1], ".")
+	if err != nil {
+		return nil, "", permissionError(err, current, true)
+	}
+	return f, current, nil
+}
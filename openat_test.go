@@ -0,0 +1,167 @@
+package fspath_test
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"reflect"
+	"testing"
+
+	"github.com/stealthrocket/fspath"
+	"github.com/stealthrocket/fstest"
+)
+
+// atFS is a stub implementing fspath.OpenAtFS which records the calls made to
+// Open, with the name opened, and to OpenAt, with the path of the directory
+// handle and the name opened relative to it. Calls reading links by name from
+// the root are recorded as well. Opening the files in denied relative to a
+// directory handle fails with fs.ErrPermission.
+type atFS struct {
+	fstest.MapFS
+	calls  []string
+	denied []string
+}
+
+type atFile struct {
+	fs.File
+	name string
+}
+
+func (fsys *atFS) Open(name string) (fs.File, error) {
+	fsys.calls = append(fsys.calls, "open:"+name)
+	return fsys.open(name)
+}
+
+func (fsys *atFS) open(name string) (fs.File, error) {
+	f, err := fsys.MapFS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &atFile{f, name}, nil
+}
+
+func (fsys *atFS) ReadLink(name string) (string, error) {
+	fsys.calls = append(fsys.calls, "readlink:"+name)
+	return fsys.MapFS.ReadLink(name)
+}
+
+func (fsys *atFS) OpenAt(dir fs.File, name string) (fs.File, error) {
+	d := dir.(*atFile)
+	fsys.calls = append(fsys.calls, d.name+":"+name)
+	for _, denied := range fsys.denied {
+		if path.Join(d.name, name) == denied {
+			return nil, &fs.PathError{Op: "openat", Path: name, Err: fs.ErrPermission}
+		}
+	}
+	return fsys.open(path.Join(d.name, name))
+}
+
+func (fsys *atFS) ReadLinkAt(dir fs.File, name string) (string, error) {
+	d := dir.(*atFile)
+	return fsys.MapFS.ReadLink(path.Join(d.name, name))
+}
+
+func TestOpenDir(t *testing.T) {
+	fsys := &atFS{MapFS: fstest.MapFS{
+		"a/b":        &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../c/d")},
+		"c/d/file":   &fstest.MapFile{Mode: 0644, Data: []byte("hello")},
+		"c/d/link":   &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("e/file")},
+		"c/d/e/x":    &fstest.MapFile{Mode: 0644},
+		"c/d/up":     &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../../f")},
+		"f":          &fstest.MapFile{Mode: 0644, Data: []byte("world")},
+		"c/d/e/file": &fstest.MapFile{Mode: 0644, Data: []byte("linked")},
+	}}
+
+	d, err := fspath.OpenDir(fsys, "a/b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	if p := d.Path(); p != "c/d" {
+		t.Errorf("wrong directory path: want=c/d got=%s", p)
+	}
+	if want := []string{"open:.", ".:a", ".:c", "c:d"}; !reflect.DeepEqual(fsys.calls, want) {
+		t.Errorf("wrong calls opening the directory:\nwant=%q\ngot= %q", want, fsys.calls)
+	}
+
+	for _, test := range []struct {
+		name  string
+		data  string
+		calls []string
+	}{
+		{name: "file", data: "hello", calls: []string{"c/d:file"}},
+		{name: "link", data: "linked", calls: []string{"c/d:e", "c/d/e:file"}},
+		// Links walking above the directory are resolved from the root.
+		{name: "up", data: "world", calls: []string{"open:.", ".:f"}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			fsys.calls = nil
+
+			f, err := d.Open(test.name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+
+			b, err := io.ReadAll(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != test.data {
+				t.Errorf("wrong file content: want=%q got=%q", test.data, b)
+			}
+			if !reflect.DeepEqual(fsys.calls, test.calls) {
+				t.Errorf("wrong calls:\nwant=%q\ngot= %q", test.calls, fsys.calls)
+			}
+		})
+	}
+}
+
+func TestDirOpenPermissionError(t *testing.T) {
+	fsys := &atFS{
+		MapFS: fstest.MapFS{
+			"c/d/e/x": &fstest.MapFile{Mode: 0644},
+		},
+		denied: []string{"c/d/e"},
+	}
+
+	d, err := fspath.OpenDir(fsys, "c/d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	for _, test := range []struct {
+		name  string
+		path  string
+		final bool
+	}{
+		{name: "e", path: "c/d/e", final: true},
+		{name: "e/x", path: "c/d/e", final: false},
+	} {
+		_, err := d.Open(test.name)
+
+		var perm *fspath.PermissionError
+		if !errors.As(err, &perm) {
+			t.Errorf("%s: expected *fspath.PermissionError, got %v", test.name, err)
+			continue
+		}
+		if perm.Path != test.path || perm.Final != test.final {
+			t.Errorf("%s: wrong permission error: want=%q (%t) got=%q (%t)", test.name, test.path, test.final, perm.Path, perm.Final)
+		}
+		if !errors.Is(err, fs.ErrPermission) {
+			t.Errorf("%s: expected fs.ErrPermission, got %v", test.name, err)
+		}
+	}
+}
+
+func TestOpenDirNotDir(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a": &fstest.MapFile{Mode: 0644},
+	}
+	if _, err := fspath.OpenDir(fsys, "a"); err == nil {
+		t.Error("opening a file as a directory did not fail")
+	}
+}
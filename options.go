@@ -0,0 +1,298 @@
+package fspath
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"time"
+)
+
+// Option is a type used to configure the behavior of LookupWith.
+type Option func(*options)
+
+type options struct {
+	ctx      context.Context
+	maxLinks int
+	maxOps   int
+	onLink   func(LinkStep)
+	onDir    func(fs.FS, string) error
+	prefetch bool
+
+	dirEntryLinks bool
+
+	linkFilter func(prefix, target string) bool
+
+	stats *stats
+
+	attempts    int
+	backoff     time.Duration
+	isTransient func(error) bool
+
+	fallback fs.FS
+
+	preserveLinks            bool
+	dereferenceEscapingLinks bool
+
+	descendFilter func(string, fs.DirEntry) bool
+
+	windowsLinkTargets bool
+
+	assumeCanonical bool
+
+	maxDepth int
+
+	linkCache *linkCache
+
+	accessCheck func(string, fs.FileInfo) error
+
+	absoluteLinks func(string) (fs.FS, string, error)
+
+	noFollowFinal bool
+
+	deadline time.Time
+
+	dirCache *dirCache
+
+	recorder *Recorder
+
+	dereferenceLinks bool
+
+	// forceWalk disables the shortcut taken for file systems which do not
+	// support symbolic links, so the path is walked one component at a time.
+	forceWalk bool
+}
+
+// defaultOptions is used by functions which do not accept options, it must
+// never be modified.
+var defaultOptions = newOptions(nil)
+
+func newOptions(opts []Option) *options {
+	// 40 is the maximum number of symbolic link lookups allowed by Linux,
+	// assume there was a valid reason behind picking this value and do the
+	// same so at least we are not changing the behavior of applications
+	// that would have worked when using an os.DirFS directly.
+	o := &options{maxLinks: 40}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+func withMaxLinks(n int) Option {
+	return func(o *options) { o.maxLinks = n }
+}
+
+func withDeadline(t time.Time) Option {
+	return func(o *options) { o.deadline = t }
+}
+
+// WithMaxOps limits the number of calls made to the underlying file system
+// (e.g. ReadLink, Sub) when resolving a path. Lookups exceeding the limit
+// fail with ErrBudgetExceeded. The count is reset on each call to LookupWith.
+//
+// Zero or negative values mean there is no limit, which is the default.
+func WithMaxOps(n int) Option {
+	return func(o *options) { o.maxOps = n }
+}
+
+// WithMaxDepth limits the number of components of the paths being resolved,
+// including the paths rewritten by following symbolic links. Lookups exceeding
+// the limit fail with ErrTooDeep.
+//
+// Zero or negative values mean there is no limit, which is the default.
+func WithMaxDepth(n int) Option {
+	return func(o *options) { o.maxDepth = n }
+}
+
+// WithAccessCheck configures a function called for each directory traversed
+// while resolving a path, with the path of the directory relative to the root
+// and information about it. When the function returns an error, the resolution
+// is aborted and the error is returned as-is.
+//
+// The function is called for the directories traversed to resolve the targets
+// of symbolic links as well, allowing callers to enforce access policies (e.g.
+// deny traversal of world-writable directories) regardless of how the path
+// was constructed. The root of the file system and the final component of the
+// path are not checked.
+func WithAccessCheck(check func(prefix string, info fs.FileInfo) error) Option {
+	return func(o *options) { o.accessCheck = check }
+}
+
+// WithAbsoluteLinkResolver configures a function called when the target of a
+// symbolic link is an absolute path, returning the file system and the path in
+// this file system that the target maps to (e.g. redirecting /shared/... to a
+// mounted volume). An error returned by the function aborts the resolution and
+// is returned as-is. By default, absolute targets cause the resolution to fail
+// with an error wrapping fs.ErrNotExist.
+//
+// The resolution continues in the returned file system, which becomes the root
+// that links containing ".." segments are clamped to. The paths reported after
+// following such a link, like the canonical path returned by Resolve, are then
+// relative to the returned file system.
+func WithAbsoluteLinkResolver(resolve func(target string) (fs.FS, string, error)) Option {
+	return func(o *options) { o.absoluteLinks = resolve }
+}
+
+// WithFollowFinal configures whether the symbolic link on the final component
+// of a path is followed, which is the default. Links on the other components
+// are always followed.
+//
+// When follow is false, Lookup returns the view of the file system positioned
+// on the directory containing the link, the Stat method of a Resolver behaves
+// like Lstat, and the other operations (e.g. Open, ReadDir) fail with an error
+// wrapping ErrLoop if the final component is a link, like opening a file with
+// O_NOFOLLOW would on posix systems.
+func WithFollowFinal(follow bool) Option {
+	return func(o *options) { o.noFollowFinal = !follow }
+}
+
+// WithPrefetch enables speculative reads of symbolic links for all the path
+// components at once, instead of reading them one at a time while walking the
+// path. This option is experimental.
+//
+// Prefetching is a best-effort latency optimization for file systems where each
+// call has a high latency (e.g. network file systems), it does not change the
+// results of the resolution. Speculation is abandoned when a symbolic link
+// rewrites the path, which may result in wasted calls to the file system.
+func WithPrefetch() Option {
+	return func(o *options) { o.prefetch = true }
+}
+
+// WithLinkFilter configures a function called for each symbolic link found
+// while resolving a path, with the path of the link relative to the root and
+// its target. When the function returns false, the link is not followed and
+// the path component is treated as if it was a regular file.
+func WithLinkFilter(filter func(prefix, target string) bool) Option {
+	return func(o *options) { o.linkFilter = filter }
+}
+
+// WithStats enables the collection of statistics about path resolutions.
+//
+// The option is intended to be passed to RootFS, the counters can then be
+// retrieved by calling the Stats method of the returned file system:
+//
+//	fsys := fspath.RootFS(base, fspath.WithStats())
+//	...
+//	stats := fsys.(interface{ Stats() fspath.Stats }).Stats()
+func WithStats() Option {
+	return func(o *options) { o.stats = new(stats) }
+}
+
+// WithDirEntryLinks configures the resolution to read the entries of each
+// directory in order to determine whether a path component is a symbolic link,
+// and only call ReadLink on components reported as links.
+//
+// This option is useful with file systems where calling ReadLink is expensive
+// (e.g. it requires opening the file), but where the type of directory entries
+// is cheap to obtain. It is ignored when combined with WithPrefetch.
+func WithDirEntryLinks() Option {
+	return func(o *options) { o.dirEntryLinks = true }
+}
+
+// WithContext configures the resolution to be aborted when ctx is canceled.
+//
+// Since the fs.FS interface does not accept contexts, the context is checked
+// before each call made to the underlying file system while resolving a path;
+// calls that were already started are not interrupted.
+func WithContext(ctx context.Context) Option {
+	return func(o *options) { o.ctx = ctx }
+}
+
+// WithRetry configures the resolution to retry the calls made to the underlying
+// file system (e.g. ReadLink, Sub) up to attempts times when they fail with a
+// transient error, waiting for backoff between each attempt.
+//
+// By default all errors are considered transient, except those matching
+// fs.ErrNotExist, fs.ErrInvalid, or fs.ErrPermission, which describe the state
+// of the file system rather than a failure to access it. WithTransient may be
+// used to narrow down which errors are retried.
+//
+// Links read speculatively when using WithPrefetch are not retried.
+func WithRetry(attempts int, backoff time.Duration) Option {
+	return func(o *options) { o.attempts, o.backoff = attempts, backoff }
+}
+
+// WithTransient configures the predicate used to determine whether an error
+// returned by the underlying file system is transient and should be retried
+// according to WithRetry. Errors matching fs.ErrNotExist or fs.ErrInvalid are
+// never retried regardless of the predicate.
+func WithTransient(isTransient func(err error) bool) Option {
+	return func(o *options) { o.isTransient = isTransient }
+}
+
+// WithWindowsLinkTargets configures the resolution to interpret the targets of
+// symbolic links as Windows paths, which is useful when processing archives
+// authored on Windows. Backslashes are converted to forward slashes, and the
+// absolute targets like "C:\data\file" or "\data\file" are stripped from their
+// drive letter and resolved relative to the root of the file system instead of
+// being rejected.
+//
+// The option also applies to the targets of links rewritten by CopyTree.
+func WithWindowsLinkTargets() Option {
+	return func(o *options) { o.windowsLinkTargets = true }
+}
+
+// WithAssumeCanonical configures the resolution to skip probing path components
+// for symbolic links, the path is passed to the underlying file system as-is.
+//
+// This option is unsafe: it must only be used when the caller guarantees that
+// paths are already canonical and contain no symbolic links, since the links
+// are otherwise not followed by the resolver and the file system may follow
+// them itself, without the protections normally offered by this package (e.g.
+// links escaping the root of a RootFS).
+func WithAssumeCanonical() Option {
+	return func(o *options) { o.assumeCanonical = true }
+}
+
+func (o *options) transient(err error) bool {
+	switch {
+	case errors.Is(err, fs.ErrNotExist), errors.Is(err, fs.ErrInvalid):
+		return false
+	case o.isTransient != nil:
+		return o.isTransient(err)
+	default:
+		return !errors.Is(err, fs.ErrPermission)
+	}
+}
+
+// canceled returns the error of the context configured with WithContext if it
+// was canceled, or context.DeadlineExceeded if the deadline set by Deadline has
+// passed.
+func (o *options) canceled() error {
+	if o.ctx != nil {
+		if err := o.ctx.Err(); err != nil {
+			return err
+		}
+	}
+	if !o.deadline.IsZero() && !time.Now().Before(o.deadline) {
+		return context.DeadlineExceeded
+	}
+	return nil
+}
+
+// sleep waits for d, returning early with the context error if the context
+// configured with WithContext is canceled, or if the deadline set by Deadline
+// passes.
+func (o *options) sleep(d time.Duration) error {
+	var expired error
+	if !o.deadline.IsZero() {
+		if left := time.Until(o.deadline); left < d {
+			if left < 0 {
+				left = 0
+			}
+			d, expired = left, context.DeadlineExceeded
+		}
+	}
+	if o.ctx == nil {
+		time.Sleep(d)
+		return expired
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return expired
+	case <-o.ctx.Done():
+		return o.ctx.Err()
+	}
+}
@@ -0,0 +1,330 @@
+package fspath_test
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stealthrocket/fspath"
+	"github.com/stealthrocket/fstest"
+)
+
+func TestWithMaxOps(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a/b/c/d/e/f": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+	}
+
+	if _, _, err := fspath.LookupWith(fsys, "a/b/c/d/e/f", fspath.WithMaxOps(4)); !errors.Is(err, fspath.ErrBudgetExceeded) {
+		t.Errorf("expected fspath.ErrBudgetExceeded, got %v", err)
+	}
+
+	dir, base, err := fspath.LookupWith(fsys, "a/b/c/d/e/f", fspath.WithMaxOps(100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.Stat(dir, base); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestWithLinkFilter(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a":   &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"a/b": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../../c")},
+		"a/c": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../c")},
+		"c/d": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+	}
+
+	var links []string
+	noEscape := fspath.WithLinkFilter(func(prefix, target string) bool {
+		links = append(links, prefix)
+		return !strings.HasPrefix(target, "../../")
+	})
+
+	dir, base, err := fspath.LookupWith(fsys, "a/c/d", noEscape)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.ReadFile(dir, base); err != nil {
+		t.Error(err)
+	}
+
+	dir, base, err = fspath.LookupWith(fsys, "a/b/d", noEscape)
+	if err == nil {
+		_, err = fs.ReadFile(dir, base)
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected fs.ErrNotExist, got %v", err)
+	}
+
+	if want := []string{"a/c", "a/b"}; !reflect.DeepEqual(links, want) {
+		t.Errorf("wrong links: want=%q got=%q", want, links)
+	}
+}
+
+func TestWithDirEntryLinks(t *testing.T) {
+	// The entries of fstest.MapFS directories report symbolic links.
+	fsys := &countFS{fsys: fstest.MapFS{
+		"a":   &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"a/b": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../c")},
+		"c/d": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+	}}
+
+	dir, base, err := fspath.LookupWith(fsys, "a/b/d", fspath.WithDirEntryLinks())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := fs.ReadFile(dir, base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "Hello World!" {
+		t.Errorf("wrong file content: %q", b)
+	}
+	if want, got := []string{"a/b"}, fsys.calls("readlink"); !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong calls to ReadLink: want=%q got=%q", want, got)
+	}
+}
+
+var errTransient = errors.New("transient")
+
+// flakyFS fails the first call to ReadLink for each name with errTransient.
+type flakyFS struct{ *countFS }
+
+func (fsys flakyFS) ReadLink(name string) (string, error) {
+	link, err := fsys.countFS.ReadLink(name)
+	if fsys.counts("readlink")[name] == 1 {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: errTransient}
+	}
+	return link, err
+}
+
+func TestWithRetry(t *testing.T) {
+	fsys := flakyFS{&countFS{fsys: fstest.MapFS{
+		"a":   &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"a/b": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../c")},
+		"c/d": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+	}}}
+
+	if _, _, err := fspath.LookupWith(fsys, "a/b/d"); !errors.Is(err, errTransient) {
+		t.Fatalf("expected a transient error without retries, got %v", err)
+	}
+
+	isTransient := func(err error) bool { return errors.Is(err, errTransient) }
+	fsys.reset()
+
+	dir, base, err := fspath.LookupWith(fsys, "a/b/d",
+		fspath.WithRetry(2, time.Millisecond),
+		fspath.WithTransient(isTransient),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := fs.ReadFile(dir, base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "Hello World!" {
+		t.Errorf("wrong file content: %q", b)
+	}
+	if want, got := map[string]int{"a": 2, "a/b": 2, "c": 2, "c/d": 2}, fsys.counts("readlink"); !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong calls to ReadLink: want=%v got=%v", want, got)
+	}
+
+	// Errors which are not transient are not retried, the second lookup of
+	// a/x only makes a single call to ReadLink which reports that the file
+	// does not exist.
+	for i, want := range []int{2, 3} {
+		if _, _, err := fspath.LookupWith(fsys, "a/x", fspath.WithRetry(3, 0)); err != nil {
+			t.Fatal(err)
+		}
+		if n := fsys.counts("readlink")["a/x"]; n != want {
+			t.Errorf("lookup %d: wrong calls to ReadLink for a/x: want=%d got=%d", i, want, n)
+		}
+	}
+}
+
+func TestWithWindowsLinkTargets(t *testing.T) {
+	fsys := fstest.MapFS{
+		"link":      &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte(`C:\data\file`)},
+		"a/b/link":  &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte(`\data\file`)},
+		"a/b/rel":   &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte(`..\..\data\file`)},
+		"data/file": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+	}
+
+	for _, name := range []string{"link", "a/b/link", "a/b/rel"} {
+		t.Run(name, func(t *testing.T) {
+			r, err := fspath.Resolve(fsys, name, fspath.WithWindowsLinkTargets())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r.Path != "data/file" {
+				t.Errorf("wrong resolved path: want=data/file got=%s", r.Path)
+			}
+		})
+	}
+
+	// Without the option, the target is a valid file name which does not
+	// exist in the file system.
+	if r, err := fspath.Resolve(fsys, "link"); err == nil && r.Path == "data/file" {
+		t.Error("windows link target resolved without the option")
+	}
+}
+
+func TestWithAssumeCanonical(t *testing.T) {
+	fsys := &countFS{fsys: fstest.MapFS{
+		"a":   &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("b")},
+		"b/c": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+	}}
+
+	r, err := fspath.Resolve(fsys, "a/c", fspath.WithAssumeCanonical())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Path != "a/c" {
+		t.Errorf("link was followed: want=a/c got=%s", r.Path)
+	}
+	if readLinks := fsys.calls("readlink"); len(readLinks) != 0 {
+		t.Errorf("links were read: %q", readLinks)
+	}
+
+	r, err = fspath.Resolve(fsys, "a/c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Path != "b/c" {
+		t.Errorf("link was not followed: want=b/c got=%s", r.Path)
+	}
+}
+
+func BenchmarkWithAssumeCanonical(b *testing.B) {
+	const name = "a/b/c/d/e/f/g"
+	fsys := fstest.MapFS{name: &fstest.MapFile{Mode: 0644}}
+	for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+		fsys[dir] = &fstest.MapFile{Mode: 0755 | fs.ModeDir}
+	}
+
+	for _, test := range []struct {
+		name string
+		opts []fspath.Option
+	}{
+		{name: "default"},
+		{name: "canonical", opts: []fspath.Option{fspath.WithAssumeCanonical()}},
+	} {
+		b.Run(test.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, _, err := fspath.LookupWith(fsys, name, test.opts...); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestWithMaxDepth(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a/b":           &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../c/d/e/f")},
+		"c/d/e/f/g":     &fstest.MapFile{Mode: 0644},
+		"c/d/e/f/h/i/j": &fstest.MapFile{Mode: 0644},
+	}
+
+	if _, _, err := fspath.LookupWith(fsys, "c/d/e/f/h/i/j", fspath.WithMaxDepth(4)); !errors.Is(err, fspath.ErrTooDeep) {
+		t.Errorf("expected fspath.ErrTooDeep, got %v", err)
+	}
+
+	// The original path has three components, but the link rewrites it to a
+	// path with five components.
+	if _, _, err := fspath.LookupWith(fsys, "a/b/g", fspath.WithMaxDepth(4)); !errors.Is(err, fspath.ErrTooDeep) {
+		t.Errorf("expected fspath.ErrTooDeep, got %v", err)
+	}
+
+	dir, base, err := fspath.LookupWith(fsys, "a/b/g", fspath.WithMaxDepth(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.Stat(dir, base); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestWithAccessCheck(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a":        &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"a/b":      &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../tmp")},
+		"tmp":      &fstest.MapFile{Mode: 0777 | fs.ModeDir},
+		"tmp/file": &fstest.MapFile{Mode: 0644},
+		"usr/file": &fstest.MapFile{Mode: 0644},
+	}
+
+	errDenied := errors.New("denied")
+	var prefixes []string
+	denyWorldWritable := fspath.WithAccessCheck(func(prefix string, info fs.FileInfo) error {
+		prefixes = append(prefixes, prefix)
+		if info.Mode().Perm()&0002 != 0 {
+			return errDenied
+		}
+		return nil
+	})
+
+	if _, _, err := fspath.LookupWith(fsys, "usr/file", denyWorldWritable); err != nil {
+		t.Error(err)
+	}
+	if want := []string{"usr"}; !reflect.DeepEqual(prefixes, want) {
+		t.Errorf("wrong prefixes: want=%q got=%q", want, prefixes)
+	}
+
+	prefixes = nil
+	if _, _, err := fspath.LookupWith(fsys, "a/b/file", denyWorldWritable); !errors.Is(err, errDenied) {
+		t.Errorf("expected the error of the access check, got %v", err)
+	}
+	if want := []string{"a", "tmp"}; !reflect.DeepEqual(prefixes, want) {
+		t.Errorf("wrong prefixes: want=%q got=%q", want, prefixes)
+	}
+}
+
+func TestWithAbsoluteLinkResolver(t *testing.T) {
+	shared := fstest.MapFS{
+		"config/app.conf": &fstest.MapFile{Mode: 0644, Data: []byte("shared")},
+	}
+	fsys := fstest.MapFS{
+		"app/config": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("/shared/config")},
+		"app/passwd": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("/etc/passwd")},
+		// Targets are cleaned before being passed to the resolver.
+		"app/other": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("//shared//config/")},
+	}
+
+	errDenied := errors.New("denied")
+	resolver := fspath.WithAbsoluteLinkResolver(func(target string) (fs.FS, string, error) {
+		if rel, ok := strings.CutPrefix(target, "/shared/"); ok {
+			return shared, rel, nil
+		}
+		return nil, "", errDenied
+	})
+
+	dir, base, err := fspath.LookupWith(fsys, "app/config/app.conf", resolver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := fs.ReadFile(dir, base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "shared" {
+		t.Errorf("wrong file content: %q", b)
+	}
+
+	if _, err := fspath.ReadFile(fspath.RootFS(fsys, resolver), "app/other/app.conf"); err != nil {
+		t.Error(err)
+	}
+	if _, _, err := fspath.LookupWith(fsys, "app/passwd", resolver); !errors.Is(err, errDenied) {
+		t.Errorf("expected the error of the resolver, got %v", err)
+	}
+	if _, _, err := fspath.Lookup(fsys, "app/config/app.conf"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected fs.ErrNotExist, got %v", err)
+	}
+}
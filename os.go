@@ -0,0 +1,137 @@
+package fspath
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// DirFS is like os.DirFS but the returned file system also implements
+// fslink.ReadLinkFS, allowing Lookup to resolve the symbolic links found in
+// the directory tree.
+func DirFS(dir string) fs.FS { return dirFS{os.DirFS(dir), dir} }
+
+// OSRootFS returns a RootFS over the directory tree rooted at dir. Symbolic
+// links are followed but never escape dir.
+func OSRootFS(dir string) fs.FS { return RootFS(DirFS(dir)) }
+
+type dirFS struct {
+	fs.FS
+	dir string
+}
+
+func (fsys dirFS) ReadLink(name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+	link, err := os.Readlink(filepath.Join(fsys.dir, filepath.FromSlash(name)))
+	if err != nil {
+		var pathErr *fs.PathError
+		if errors.As(err, &pathErr) {
+			err = pathErr.Err
+		}
+		if err == syscall.EINVAL {
+			err = fs.ErrInvalid
+		}
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: err}
+	}
+	return filepath.ToSlash(link), nil
+}
+
+func (fsys dirFS) Lstat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "lstat", Path: name, Err: fs.ErrInvalid}
+	}
+	info, err := os.Lstat(filepath.Join(fsys.dir, filepath.FromSlash(name)))
+	if err != nil {
+		var pathErr *fs.PathError
+		if errors.As(err, &pathErr) {
+			err = pathErr.Err
+		}
+		return nil, &fs.PathError{Op: "lstat", Path: name, Err: err}
+	}
+	return info, nil
+}
+
+func (fsys dirFS) OpenFile(name string, flag int, perm fs.FileMode) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	f, err := os.OpenFile(filepath.Join(fsys.dir, filepath.FromSlash(name)), flag, perm)
+	if err != nil {
+		var pathErr *fs.PathError
+		if errors.As(err, &pathErr) {
+			err = pathErr.Err
+		}
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return f, nil
+}
+
+func (fsys dirFS) MkdirAll(name string, perm fs.FileMode) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrInvalid}
+	}
+	return osPathError("mkdir", name, os.MkdirAll(filepath.Join(fsys.dir, filepath.FromSlash(name)), perm))
+}
+
+func (fsys dirFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "write", Path: name, Err: fs.ErrInvalid}
+	}
+	return osPathError("write", name, os.WriteFile(filepath.Join(fsys.dir, filepath.FromSlash(name)), data, perm))
+}
+
+func (fsys dirFS) Symlink(oldname, newname string) error {
+	if !fs.ValidPath(newname) {
+		return &fs.PathError{Op: "symlink", Path: newname, Err: fs.ErrInvalid}
+	}
+	return osPathError("symlink", newname, os.Symlink(filepath.FromSlash(oldname), filepath.Join(fsys.dir, filepath.FromSlash(newname))))
+}
+
+func (fsys dirFS) SameFile(fi1, fi2 fs.FileInfo) bool {
+	return os.SameFile(fi1, fi2)
+}
+
+// osPathError rewrites errors returned by the os package to report name, which
+// is relative to the root of the file system, instead of the absolute path.
+func osPathError(op, name string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		err = pathErr.Err
+	}
+	var linkErr *os.LinkError
+	if errors.As(err, &linkErr) {
+		err = linkErr.Err
+	}
+	return &fs.PathError{Op: op, Path: name, Err: err}
+}
+
+// OpenOSFile opens the file at name in fsys, following symbolic links, and
+// returns it as an *os.File.
+//
+// The function returns ErrNotOSFile if the file system did not open a file of
+// the operating system. Files wrapping an *os.File are unwrapped if they have
+// an Unwrap() fs.File method.
+func OpenOSFile(fsys fs.FS, name string) (*os.File, error) {
+	f, err := Open(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch file := f.(type) {
+		case *os.File:
+			return file, nil
+		case interface{ Unwrap() fs.File }:
+			f = file.Unwrap()
+		default:
+			f.Close()
+			return nil, &fs.PathError{Op: "open", Path: name, Err: ErrNotOSFile}
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package fspath_test
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stealthrocket/fspath"
+	"github.com/stealthrocket/fstest"
+)
+
+func makeDirTree(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "a"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "c"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "c", "d"), []byte("Hello World!"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("../../c", filepath.Join(dir, "a", "b")); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestOpenOSFile(t *testing.T) {
+	fsys := fspath.OSRootFS(makeDirTree(t))
+
+	f, err := fspath.OpenOSFile(fsys, "a/b/d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	b, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "Hello World!" {
+		t.Errorf("wrong file content: %q", b)
+	}
+
+	mapFS := fstest.MapFS{
+		"c/d": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+	}
+	if _, err := fspath.OpenOSFile(mapFS, "c/d"); !errors.Is(err, fspath.ErrNotOSFile) {
+		t.Errorf("expected fspath.ErrNotOSFile, got %v", err)
+	}
+}
+
+func TestOSRootFS(t *testing.T) {
+	fsys := fspath.OSRootFS(makeDirTree(t))
+
+	link, err := fspath.ReadLink(fsys, "a/b")
+	if err != nil {
+		t.Error(err)
+	} else if link != "../../c" {
+		t.Errorf("wrong link: %q", link)
+	}
+
+	if _, err := fspath.ReadLink(fsys, "c/d"); !errors.Is(err, fs.ErrInvalid) {
+		t.Errorf("expected fs.ErrInvalid, got %v", err)
+	}
+
+	if _, err := fs.Stat(fsys, "c/d/e"); !errors.Is(err, fs.ErrInvalid) {
+		t.Errorf("expected fs.ErrInvalid, got %v", err)
+	}
+
+	if err := fstest.TestFS(fsys, "a", "a/b", "c/d"); err != nil {
+		t.Error(err)
+	}
+}
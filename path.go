@@ -0,0 +1,149 @@
+package fspath
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// SafeJoin joins the path elements like path.Join, and verifies that the result
+// remains within the root of the file system. The function returns ErrEscape
+// if the joined path points above the root, for example:
+//
+//	SafeJoin("a", "../../x") => ErrEscape
+//
+// Contrary to path.Join, joining no elements or only empty elements returns ".".
+func SafeJoin(elems ...string) (string, error) {
+	name := path.Join(elems...)
+	switch {
+	case name == "":
+		return ".", nil
+	case name == ".." || strings.HasPrefix(name, "../"):
+		return "", &fs.PathError{Op: "join", Path: name, Err: ErrEscape}
+	case !fs.ValidPath(name):
+		return "", &fs.PathError{Op: "join", Path: name, Err: fs.ErrInvalid}
+	}
+	return name, nil
+}
+
+// CleanName validates that name is a clean slash-separated path relative to the
+// root of a file system, as accepted by fs.ValidPath, and returns it.
+//
+// CleanName is stricter than fs.ValidPath: it also rejects backslashes, which
+// usually indicate a Windows path, and control characters. When the name is
+// invalid, the returned error wraps fs.ErrInvalid and describes the rule that
+// was violated, like the errors returned by Lookup for names rejected by
+// fs.ValidPath.
+func CleanName(name string) (string, error) {
+	if err := checkPath(name); err != nil {
+		return "", &fs.PathError{Op: "clean", Path: name, Err: err}
+	}
+	for i, c := range name {
+		switch {
+		case c == '\\':
+			return "", &fs.PathError{Op: "clean", Path: name, Err: fmt.Errorf("backslash at offset %d: %w", i, fs.ErrInvalid)}
+		case c < 0x20 || c == 0x7f:
+			return "", &fs.PathError{Op: "clean", Path: name, Err: fmt.Errorf("control character %q at offset %d: %w", c, i, fs.ErrInvalid)}
+		}
+	}
+	return name, nil
+}
+
+// checkPath returns nil if name is a valid path according to fs.ValidPath, or
+// an error wrapping fs.ErrInvalid describing why it is not.
+func checkPath(name string) error {
+	if fs.ValidPath(name) {
+		return nil
+	}
+	switch {
+	case name == "":
+		return fmt.Errorf("empty path: %w", fs.ErrInvalid)
+	case name[0] == '/':
+		return fmt.Errorf("leading slash: %w", fs.ErrInvalid)
+	case name[len(name)-1] == '/':
+		return fmt.Errorf("trailing slash: %w", fs.ErrInvalid)
+	}
+	for i, elem := range strings.Split(name, "/") {
+		switch elem {
+		case "":
+			return fmt.Errorf("empty segment at index %d: %w", i, fs.ErrInvalid)
+		case ".", "..":
+			return fmt.Errorf("%q segment at index %d: %w", elem, i, fs.ErrInvalid)
+		}
+	}
+	return fmt.Errorf("invalid path: %w", fs.ErrInvalid)
+}
+
+// ExpandHome expands a leading "~" segment of name to home, which must be a
+// valid path relative to the root of the file system, for example:
+//
+//	ExpandHome("~/docs", "home/me") => "home/me/docs"
+//	ExpandHome("~", "home/me")      => "home/me"
+//
+// Names which do not start with "~" are returned unchanged. The "~user" form
+// is not supported and causes the function to return an error wrapping
+// ErrUnsupported. The expanded path must be valid according to fs.ValidPath,
+// otherwise an error wrapping fs.ErrInvalid is returned.
+func ExpandHome(name, home string) (string, error) {
+	if !strings.HasPrefix(name, "~") {
+		return name, nil
+	}
+	if err := checkPath(home); err != nil {
+		return "", &fs.PathError{Op: "expand", Path: home, Err: err}
+	}
+	rest := name[1:]
+	switch {
+	case rest == "":
+		return home, nil
+	case rest[0] != '/':
+		return "", &fs.PathError{Op: "expand", Path: name, Err: ErrUnsupported}
+	}
+	expanded := home + rest
+	if home == "." {
+		expanded = rest[1:]
+	}
+	if err := checkPath(expanded); err != nil {
+		return "", &fs.PathError{Op: "expand", Path: name, Err: err}
+	}
+	return expanded, nil
+}
+
+// windowsLinkTarget converts the Windows path of a link target to a slash
+// separated path, the returned boolean is true if the target was absolute, in
+// which case the leading drive letter and slashes are stripped.
+func windowsLinkTarget(link string) (string, bool) {
+	link = strings.ReplaceAll(link, `\`, "/")
+	rooted := false
+	if len(link) >= 2 && link[1] == ':' && isDriveLetter(link[0]) {
+		link, rooted = link[2:], true
+	}
+	if strings.HasPrefix(link, "/") {
+		link, rooted = strings.TrimLeft(link, "/"), true
+	}
+	if rooted && link == "" {
+		link = "."
+	}
+	return link, rooted
+}
+
+func isDriveLetter(c byte) bool {
+	return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+// CanonicalWithTrailing cleans name like SafeJoin, and reports whether it had a
+// trailing slash, which tools like rsync interpret as referring to the content
+// of a directory rather than the directory itself.
+//
+//	CanonicalWithTrailing("a/b/") => "a/b", true, nil
+//	CanonicalWithTrailing("a/b")  => "a/b", false, nil
+func CanonicalWithTrailing(name string) (clean string, wasDir bool, err error) {
+	if name == "" {
+		return "", false, &fs.PathError{Op: "canonical", Path: name, Err: fs.ErrInvalid}
+	}
+	clean, err = SafeJoin(name)
+	if err != nil {
+		return "", false, err
+	}
+	return clean, strings.HasSuffix(name, "/"), nil
+}
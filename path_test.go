@@ -0,0 +1,133 @@
+package fspath_test
+
+import (
+	"errors"
+	"io/fs"
+	"strings"
+	"testing"
+
+	"github.com/stealthrocket/fspath"
+)
+
+func TestSafeJoin(t *testing.T) {
+	for _, test := range [...]struct {
+		elems []string
+		name  string
+		err   error
+	}{
+		{elems: nil, name: "."},
+		{elems: []string{"a"}, name: "a"},
+		{elems: []string{"a", "b/c"}, name: "a/b/c"},
+		{elems: []string{"a/b", "../c"}, name: "a/c"},
+		{elems: []string{"a", ".."}, name: "."},
+		{elems: []string{"a", "../../x"}, err: fspath.ErrEscape},
+		{elems: []string{"..", "a"}, err: fspath.ErrEscape},
+		{elems: []string{"/a"}, err: fs.ErrInvalid},
+	} {
+		name, err := fspath.SafeJoin(test.elems...)
+		if test.err != nil {
+			if !errors.Is(err, test.err) {
+				t.Errorf("%q: expected %v, got %v", test.elems, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", test.elems, err)
+		} else if name != test.name {
+			t.Errorf("%q: want=%q got=%q", test.elems, test.name, name)
+		}
+	}
+}
+
+func TestCleanName(t *testing.T) {
+	for _, name := range []string{".", "a", "a/b", "a/b.c", "a/..b", "a b/%2F"} {
+		clean, err := fspath.CleanName(name)
+		if err != nil {
+			t.Errorf("%q: %v", name, err)
+		} else if clean != name {
+			t.Errorf("%q: wrong name: %q", name, clean)
+		}
+	}
+
+	for _, test := range [...]struct {
+		name string
+		msg  string
+	}{
+		{name: "", msg: "empty path"},
+		{name: "/a", msg: "leading slash"},
+		{name: "a/", msg: "trailing slash"},
+		{name: "a//b", msg: "empty segment at index 1"},
+		{name: "a/./b", msg: `"." segment at index 1`},
+		{name: "../a", msg: `".." segment at index 0`},
+		{name: `a\b`, msg: "backslash at offset 1"},
+		{name: "a/b\x00", msg: `control character '\x00' at offset 3`},
+		{name: "a\tb", msg: `control character '\t' at offset 1`},
+	} {
+		_, err := fspath.CleanName(test.name)
+		if !errors.Is(err, fs.ErrInvalid) {
+			t.Errorf("%q: expected fs.ErrInvalid, got %v", test.name, err)
+		} else if !strings.Contains(err.Error(), test.msg) {
+			t.Errorf("%q: error message does not mention %q: %v", test.name, test.msg, err)
+		}
+	}
+}
+
+func TestCanonicalWithTrailing(t *testing.T) {
+	for _, test := range [...]struct {
+		name   string
+		clean  string
+		wasDir bool
+	}{
+		{name: "a/b/", clean: "a/b", wasDir: true},
+		{name: "a/b", clean: "a/b", wasDir: false},
+		{name: "a//b/./", clean: "a/b", wasDir: true},
+		{name: "a/../b", clean: "b", wasDir: false},
+		{name: "./", clean: ".", wasDir: true},
+	} {
+		clean, wasDir, err := fspath.CanonicalWithTrailing(test.name)
+		if err != nil {
+			t.Errorf("%q: %v", test.name, err)
+			continue
+		}
+		if clean != test.clean || wasDir != test.wasDir {
+			t.Errorf("%q: want=(%q,%t) got=(%q,%t)", test.name, test.clean, test.wasDir, clean, wasDir)
+		}
+	}
+
+	for _, name := range []string{"", "../a/", "/a/"} {
+		if _, _, err := fspath.CanonicalWithTrailing(name); err == nil {
+			t.Errorf("%q: expected an error", name)
+		}
+	}
+}
+
+func TestExpandHome(t *testing.T) {
+	for _, test := range [...]struct {
+		name     string
+		home     string
+		expanded string
+	}{
+		{name: "~/docs", home: "home/me", expanded: "home/me/docs"},
+		{name: "~", home: "home/me", expanded: "home/me"},
+		{name: "~/docs", home: ".", expanded: "docs"},
+		{name: "a/~/b", home: "home/me", expanded: "a/~/b"},
+	} {
+		expanded, err := fspath.ExpandHome(test.name, test.home)
+		if err != nil {
+			t.Errorf("%q: %v", test.name, err)
+			continue
+		}
+		if expanded != test.expanded {
+			t.Errorf("%q: want=%q got=%q", test.name, test.expanded, expanded)
+		}
+	}
+
+	if _, err := fspath.ExpandHome("~user/x", "home/me"); !errors.Is(err, fspath.ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported, got %v", err)
+	}
+	for _, name := range []string{"~/", "~/../x", "~//x"} {
+		if _, err := fspath.ExpandHome(name, "home/me"); !errors.Is(err, fs.ErrInvalid) {
+			t.Errorf("%q: expected fs.ErrInvalid, got %v", name, err)
+		}
+	}
+}
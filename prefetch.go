@@ -0,0 +1,55 @@
+package fspath
+
+import (
+	"io/fs"
+	"sync/atomic"
+
+	"github.com/stealthrocket/fslink"
+)
+
+// LookupPrefetch is like Lookup but it prefetches symbolic links for each path
+// component concurrently. It is equivalent to calling LookupWith with the
+// WithPrefetch option.
+func LookupPrefetch(fsys fs.FS, name string) (fs.FS, string, error) {
+	return LookupWith(fsys, name, WithPrefetch())
+}
+
+type prefetcher struct {
+	done    atomic.Bool
+	results []chan prefetchResult
+}
+
+type prefetchResult struct {
+	link string
+	err  error
+}
+
+// startPrefetch issues concurrent ReadLink calls for each prefix of name.
+//
+// Reading "a/b" from the root of fsys is equivalent to reading "b" from the
+// sub-directory "a" as long as "a" is not a symbolic link, in which case the
+// resolution restarts and the remaining results are discarded.
+func startPrefetch(fsys fslink.ReadLinkFS, name string) *prefetcher {
+	p := new(prefetcher)
+	Walk(name, func(prefix string) error {
+		ch := make(chan prefetchResult, 1)
+		p.results = append(p.results, ch)
+		go func() {
+			if p.done.Load() {
+				ch <- prefetchResult{err: fs.ErrNotExist}
+				return
+			}
+			link, err := fsys.ReadLink(prefix)
+			ch <- prefetchResult{link, err}
+		}()
+		return nil
+	})
+	return p
+}
+
+func (p *prefetcher) readLink(i int) (string, error) {
+	r := <-p.results[i]
+	return r.link, r.err
+}
+
+func (p *prefetcher) cancel() { p.done.Store(true) }
@@ -0,0 +1,107 @@
+package fspath_test
+
+import (
+	"fmt"
+	"io/fs"
+	"testing"
+	"time"
+
+	"github.com/stealthrocket/fspath"
+	"github.com/stealthrocket/fstest"
+)
+
+// slowFS injects latency in each call to ReadLink.
+type slowFS struct {
+	*countFS
+	delay time.Duration
+}
+
+func (fsys slowFS) ReadLink(name string) (string, error) {
+	time.Sleep(fsys.delay)
+	return fsys.countFS.ReadLink(name)
+}
+
+func TestLookupPrefetch(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a":       &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"a/b":     &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../../c")},
+		"a/c":     &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("b/d")},
+		"a/dot":   &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte(".")},
+		"a/up":    &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("..")},
+		"a/miss":  &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../missing")},
+		"c/d":     &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+		"l/a":     &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("b")},
+		"l/b":     &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("a")},
+		"l/self":  &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("self")},
+		"l/deep":  &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../../l/deep")},
+		"x/y/z/w": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+	}
+
+	names := []string{
+		".",
+		"c/d",
+		"a/b",
+		"a/b/d",
+		"a/c",
+		"a/dot/b/d",
+		"a/up",
+		"a/up/c/d",
+		"a/miss",
+		"a/miss/x",
+		"a/b/x",
+		"c/d/x",
+		"l/a",
+		"l/a/x",
+		"l/self",
+		"l/deep",
+		"x/y/z/w",
+		"x/y/missing/w",
+	}
+
+	for _, backend := range []struct {
+		name string
+		fsys fs.FS
+	}{
+		{name: "map", fsys: fsys},
+		{name: "slow", fsys: slowFS{countFS: &countFS{fsys: fsys}}},
+	} {
+		for _, name := range names {
+			want, wantErr := fspath.Resolve(backend.fsys, name)
+			got, gotErr := fspath.Resolve(backend.fsys, name, fspath.WithPrefetch())
+
+			if want.Path != got.Path || want.Base != got.Base || want.AtRoot != got.AtRoot {
+				t.Errorf("%s: %s: mismatch: want=(%q,%q,%t) got=(%q,%q,%t)", backend.name, name,
+					want.Path, want.Base, want.AtRoot, got.Path, got.Base, got.AtRoot)
+			}
+			if fmt.Sprint(wantErr) != fmt.Sprint(gotErr) {
+				t.Errorf("%s: %s: error mismatch: want=%v got=%v", backend.name, name, wantErr, gotErr)
+			}
+		}
+	}
+}
+
+func BenchmarkLookupPrefetch(b *testing.B) {
+	const name = "a/b/c/d/e/f/g/h"
+	fsys := slowFS{
+		countFS: &countFS{fsys: fstest.MapFS{
+			name: &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+		}},
+		delay: 100 * time.Microsecond,
+	}
+
+	b.Run("serial", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, _, err := fspath.Lookup(fsys, name); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("prefetch", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, _, err := fspath.LookupPrefetch(fsys, name); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
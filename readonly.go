@@ -0,0 +1,121 @@
+package fspath
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+
+	"github.com/stealthrocket/fslink"
+)
+
+// errReadOnly is the equivalent of EROFS, it wraps fs.ErrPermission so callers
+// can use errors.Is to test for permission errors.
+var errReadOnly = fmt.Errorf("read-only file system: %w", fs.ErrPermission)
+
+// ReadOnly returns a file system wrapping fsys which rejects all attempts to
+// modify it, even if fsys would permit them.
+//
+// The wrapper implements the usual write methods (WriteFile, Mkdir, Remove,
+// etc...) with signatures mirroring the os package, and always returns an
+// error wrapping fs.ErrPermission from them. OpenFile only succeeds when
+// called with os.O_RDONLY. Read operations are passed through to fsys, which
+// means that wrapping a RootFS retains its resolution of symbolic links.
+func ReadOnly(fsys fs.FS) fs.FS { return readOnlyFS{fsys} }
+
+type readOnlyFS struct{ fs fs.FS }
+
+func readOnlyError(op, name string) error {
+	return &fs.PathError{Op: op, Path: name, Err: errReadOnly}
+}
+
+func (fsys readOnlyFS) Open(name string) (fs.File, error) {
+	return fsys.fs.Open(name)
+}
+
+func (fsys readOnlyFS) Stat(name string) (fs.FileInfo, error) {
+	return fs.Stat(fsys.fs, name)
+}
+
+func (fsys readOnlyFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return fs.ReadDir(fsys.fs, name)
+}
+
+func (fsys readOnlyFS) ReadFile(name string) ([]byte, error) {
+	return fs.ReadFile(fsys.fs, name)
+}
+
+func (fsys readOnlyFS) ReadLink(name string) (string, error) {
+	return fslink.ReadLink(fsys.fs, name)
+}
+
+func (fsys readOnlyFS) Lstat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "lstat", Path: name, Err: fs.ErrInvalid}
+	}
+	dir, base := path.Split(name)
+	if dir == "" {
+		return lstat(fsys.fs, base)
+	}
+	sub, err := fslink.Sub(fsys.fs, dir[:len(dir)-1])
+	if err != nil {
+		return nil, err
+	}
+	return lstat(sub, base)
+}
+
+func (fsys readOnlyFS) Sub(name string) (fs.FS, error) {
+	sub, err := fslink.Sub(fsys.fs, name)
+	if err != nil {
+		return nil, err
+	}
+	return readOnlyFS{sub}, nil
+}
+
+func (fsys readOnlyFS) OpenFile(name string, flag int, perm fs.FileMode) (fs.File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_CREATE|os.O_TRUNC) != 0 {
+		return nil, readOnlyError("open", name)
+	}
+	return fsys.fs.Open(name)
+}
+
+func (fsys readOnlyFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	return readOnlyError("write", name)
+}
+
+func (fsys readOnlyFS) Mkdir(name string, perm fs.FileMode) error {
+	return readOnlyError("mkdir", name)
+}
+
+func (fsys readOnlyFS) MkdirAll(name string, perm fs.FileMode) error {
+	return readOnlyError("mkdir", name)
+}
+
+func (fsys readOnlyFS) Remove(name string) error {
+	return readOnlyError("remove", name)
+}
+
+func (fsys readOnlyFS) RemoveAll(name string) error {
+	return readOnlyError("remove", name)
+}
+
+func (fsys readOnlyFS) Rename(oldname, newname string) error {
+	return readOnlyError("rename", oldname)
+}
+
+func (fsys readOnlyFS) Symlink(oldname, newname string) error {
+	return readOnlyError("symlink", newname)
+}
+
+func (fsys readOnlyFS) Chmod(name string, mode fs.FileMode) error {
+	return readOnlyError("chmod", name)
+}
+
+var (
+	_ fs.StatFS         = readOnlyFS{}
+	_ fs.ReadDirFS      = readOnlyFS{}
+	_ fs.ReadFileFS     = readOnlyFS{}
+	_ fs.SubFS          = readOnlyFS{}
+	_ fslink.ReadLinkFS = readOnlyFS{}
+	_ ReadLinkFS        = readOnlyFS{}
+)
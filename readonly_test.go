@@ -0,0 +1,69 @@
+package fspath_test
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"testing"
+
+	"github.com/stealthrocket/fspath"
+	"github.com/stealthrocket/fstest"
+)
+
+type writeFS interface {
+	fs.FS
+	OpenFile(name string, flag int, perm fs.FileMode) (fs.File, error)
+	WriteFile(name string, data []byte, perm fs.FileMode) error
+	Mkdir(name string, perm fs.FileMode) error
+	MkdirAll(name string, perm fs.FileMode) error
+	Remove(name string) error
+	RemoveAll(name string) error
+	Rename(oldname, newname string) error
+	Symlink(oldname, newname string) error
+	Chmod(name string, mode fs.FileMode) error
+}
+
+func TestReadOnly(t *testing.T) {
+	fsys := fspath.ReadOnly(fspath.RootFS(fstest.MapFS{
+		"a":   &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"a/b": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../c")},
+		"c/d": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+	})).(writeFS)
+
+	b, err := fs.ReadFile(fsys, "a/b/d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "Hello World!" {
+		t.Errorf("wrong file content: %q", b)
+	}
+
+	f, err := fsys.OpenFile("a/b/d", os.O_RDONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	if err := fstest.TestFS(fsys, "a", "a/b", "c/d"); err != nil {
+		t.Error(err)
+	}
+
+	for op, err := range map[string]error{
+		"openfile": func() error {
+			_, err := fsys.OpenFile("c/d", os.O_WRONLY, 0)
+			return err
+		}(),
+		"writefile": fsys.WriteFile("c/e", nil, 0644),
+		"mkdir":     fsys.Mkdir("e", 0755),
+		"mkdirall":  fsys.MkdirAll("e/f", 0755),
+		"remove":    fsys.Remove("c/d"),
+		"removeall": fsys.RemoveAll("c"),
+		"rename":    fsys.Rename("c/d", "c/e"),
+		"symlink":   fsys.Symlink("d", "c/e"),
+		"chmod":     fsys.Chmod("c/d", 0600),
+	} {
+		if !errors.Is(err, fs.ErrPermission) {
+			t.Errorf("%s: expected fs.ErrPermission, got %v", op, err)
+		}
+	}
+}
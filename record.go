@@ -0,0 +1,100 @@
+package fspath
+
+import (
+	"io/fs"
+	"sync"
+)
+
+// Decision describes how a path component was resolved, as recorded by a
+// Recorder.
+type Decision struct {
+	// Path of the component, relative to the file system root.
+	Path string
+	// Link is true if the component was a symbolic link, in which case Target
+	// is set to the target of the link, as returned by ReadLink.
+	Link   bool
+	Target string
+	// Clamped is true if the link pointed above the root and was rebased off
+	// of it.
+	Clamped bool
+	// Err is the error that the resolution of the component failed with.
+	Err error
+}
+
+// Recorder records the decisions made while resolving paths, see WithRecorder.
+//
+// A Recorder is safe to use concurrently from multiple goroutines. The zero
+// value is an empty recording, ready to use.
+type Recorder struct {
+	mutex     sync.Mutex
+	decisions []Decision
+}
+
+// Decisions returns the decisions recorded so far, in order.
+func (r *Recorder) Decisions() []Decision {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]Decision(nil), r.decisions...)
+}
+
+func (r *Recorder) record(d Decision) {
+	r.mutex.Lock()
+	r.decisions = append(r.decisions, d)
+	r.mutex.Unlock()
+}
+
+// WithRecorder configures the resolution to record the decision made for each
+// path component in r: whether the component was a symbolic link, its target,
+// whether the target was clamped to the root, and the error that aborted the
+// resolution if any.
+//
+// The recording can be passed to ReplayFS to reproduce the resolutions offline,
+// for example in tests or when debugging an incident. Paths are recorded
+// relative to the root of the resolution, so the recording should not be
+// shared between different file systems. Since links redirecting to other
+// file systems would make the paths ambiguous, resolutions combining the
+// recorder with WithAbsoluteLinkResolver fail with an error wrapping
+// ErrUnsupported.
+func WithRecorder(r *Recorder) Option {
+	return func(o *options) { o.recorder = r }
+}
+
+// ReplayFS returns a file system serving the resolution of paths from the
+// decisions recorded in r so far, without accessing the file system that the
+// decisions were recorded from. Resolving a path in the returned file system
+// with the options used during the recording produces the same results.
+//
+// The file system only replays resolutions: ReadLink returns the recorded
+// targets, path components missing from the recording are treated as regular
+// files, and recorded errors are returned when resolving the components that
+// they occurred on. Opening files fails with an error wrapping ErrUnsupported.
+func ReplayFS(r *Recorder) fs.FS {
+	decisions := r.Decisions()
+	fsys := make(replayFS, len(decisions))
+	for _, d := range decisions {
+		fsys[d.Path] = d
+	}
+	return fsys
+}
+
+type replayFS map[string]Decision
+
+func (fsys replayFS) Open(name string) (fs.File, error) {
+	return nil, &fs.PathError{Op: "open", Path: name, Err: ErrUnsupported}
+}
+
+func (fsys replayFS) ReadLink(name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+	d := fsys[name]
+	switch {
+	case d.Err != nil:
+		// The error is replayed as-is, the resolution would interpret it
+		// otherwise, as if the file system had returned it.
+		return "", &abortError{d.Err}
+	case d.Link:
+		return d.Target, nil
+	}
+	return "", &fs.PathError{Op: "readlink", Path: name, Err: errNotLink}
+}
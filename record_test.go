@@ -0,0 +1,98 @@
+package fspath_test
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"reflect"
+	"testing"
+
+	"github.com/stealthrocket/fspath"
+	"github.com/stealthrocket/fstest"
+)
+
+func TestRecorder(t *testing.T) {
+	// Traversing p is denied, the permission error does not wrap
+	// fs.ErrNotExist so it is replayed as-is rather than interpreted by the
+	// resolution again.
+	fsys := deniedFS{dirs: []string{"p"}, fsys: fstest.MapFS{
+		"p/q":   &fstest.MapFile{Mode: 0644},
+		"a/b":   &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../c")},
+		"a/up":  &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../../c/d")},
+		"a/x":   &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("y")},
+		"a/y":   &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("x")},
+		"a/f/g": &fstest.MapFile{Mode: 0644},
+		"c/d":   &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+	}}
+	names := []string{
+		"a/b/d",
+		"a/up",
+		"a/x",
+		"a/f/g",
+		"a/missing/g",
+		"a/f/g/h",
+		"c/missing",
+		"p/q",
+	}
+
+	// The resolutions are replayed with the options that they were recorded
+	// with, except for the recorder.
+	opts := []fspath.Option{fspath.WithMaxDepth(8), fspath.WithFollowFinal(true)}
+	var recorder fspath.Recorder
+	record := fspath.New(append(opts, fspath.WithRecorder(&recorder))...)
+
+	type result struct {
+		res fspath.ResolveResult
+		err error
+	}
+	results := make([]result, len(names))
+	for i, name := range names {
+		results[i].res, results[i].err = record.Resolve(fsys, name)
+	}
+	if err := results[len(results)-1].err; !errors.Is(err, fs.ErrPermission) {
+		t.Fatalf("expected fs.ErrPermission, got %v", err)
+	}
+
+	replay := fspath.New(opts...)
+	replayFS := fspath.ReplayFS(&recorder)
+	for i, name := range names {
+		t.Run(name, func(t *testing.T) {
+			want, wantErr := results[i].res, results[i].err
+			got, gotErr := replay.Resolve(replayFS, name)
+			if fmt.Sprint(gotErr) != fmt.Sprint(wantErr) {
+				t.Fatalf("wrong error: want=%v got=%v", wantErr, gotErr)
+			}
+			var wantPerm, gotPerm *fspath.PermissionError
+			if errors.As(wantErr, &wantPerm) != errors.As(gotErr, &gotPerm) {
+				t.Errorf("wrong error type: want=%T got=%T", wantErr, gotErr)
+			}
+			if got.Path != want.Path || got.Base != want.Base || got.AtRoot != want.AtRoot {
+				t.Errorf("wrong resolution: want=%q (%t) got=%q (%t)", want.Path, want.AtRoot, got.Path, got.AtRoot)
+			}
+		})
+	}
+
+	recorder = fspath.Recorder{}
+	if _, err := record.Resolve(fsys, "a/up"); err != nil {
+		t.Fatal(err)
+	}
+	want := []fspath.Decision{
+		{Path: "a"},
+		{Path: "a/up", Link: true, Target: "../../c/d", Clamped: true},
+		{Path: "c"},
+		{Path: "c/d"},
+	}
+	if got := recorder.Decisions(); !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong decisions:\nwant=%+v\ngot= %+v", want, got)
+	}
+}
+
+func TestRecorderAbsoluteLinks(t *testing.T) {
+	resolver := fspath.WithAbsoluteLinkResolver(func(target string) (fs.FS, string, error) {
+		return fstest.MapFS{}, target[1:], nil
+	})
+	var recorder fspath.Recorder
+	if _, err := fspath.Resolve(fstest.MapFS{}, "a", fspath.WithRecorder(&recorder), resolver); !errors.Is(err, fspath.ErrUnsupported) {
+		t.Errorf("expected fspath.ErrUnsupported, got %v", err)
+	}
+}
@@ -0,0 +1,321 @@
+package fspath
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// ResolveResult is the result of resolving a path with Resolve.
+type ResolveResult struct {
+	// FS is the view of the file system positioned on the directory which
+	// contains the resolved file.
+	FS fs.FS
+	// Base is the name of the resolved file in FS.
+	Base string
+	// Path is the canonical path of the resolved file, relative to the root
+	// of the file system.
+	Path string
+	// AtRoot is true if a symbolic link walked back up to the root of the
+	// file system while resolving the path, either because it pointed exactly
+	// at the root or because it pointed above and was clamped.
+	AtRoot bool
+}
+
+// Resolve is like LookupWith but returns a ResolveResult with more details
+// about the resolution than Lookup.
+func Resolve(fsys fs.FS, name string, opts ...Option) (ResolveResult, error) {
+	r, err := newOptions(opts).resolve(fsys, name)
+	return r.result(), err
+}
+
+func (r *resolution) result() ResolveResult {
+	return ResolveResult{
+		FS:     r.fsys,
+		Base:   r.base,
+		Path:   r.path(),
+		AtRoot: r.atRoot,
+	}
+}
+
+// ResolvePath resolves name in fsys like Lookup, and returns information about
+// each directory traversed during the resolution, in order, followed by the
+// resolved file. This is useful to verify permissions at every level of the
+// path, similarly to how posix systems check the execute bit of directories.
+//
+// Directories traversed before a symbolic link was followed are included,
+// followed by the directories traversed to resolve the target of the link. The
+// root of the file system is not included.
+func ResolvePath(fsys fs.FS, name string) ([]fs.FileInfo, error) {
+	var infos []fs.FileInfo
+	dir, base, err := LookupWith(fsys, name, func(o *options) {
+		o.onDir = func(dir fs.FS, base string) error {
+			info, err := fs.Stat(dir, base)
+			if err != nil {
+				return err
+			}
+			infos = append(infos, info)
+			return nil
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	info, err := fs.Stat(dir, base)
+	if err != nil {
+		return nil, err
+	}
+	return append(infos, info), nil
+}
+
+// ResolveDangling resolves name in fsys like Lookup, and reports whether the
+// resolved file exists. When it does not, for example because name is a broken
+// symbolic link, the function still returns the view of the file system
+// positioned on the directory where the file would be, and its intended base
+// name, with an error wrapping ErrDangling. This supports workflows creating
+// files at the target of links.
+//
+// Other errors, including those caused by missing intermediate directories,
+// are returned as-is with a nil file system.
+func ResolveDangling(fsys fs.FS, name string) (fs.FS, string, error) {
+	dir, base, err := Lookup(fsys, name)
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err := fs.Stat(dir, base); err != nil {
+		// Some file systems do not verify that directories exist when
+		// positioning on them, so the parent is checked as well.
+		if errors.Is(err, fs.ErrNotExist) {
+			if _, parentErr := fs.Stat(dir, "."); parentErr == nil {
+				return dir, base, &fs.PathError{Op: "lookup", Path: name, Err: ErrDangling}
+			}
+		}
+		return nil, "", err
+	}
+	return dir, base, nil
+}
+
+// ResolveParentOrError resolves name in fsys like Lookup, then checks that the
+// resolved file can be accessed with Stat. When the resolution succeeds but the
+// final component fails (e.g. because it does not exist or access to it is
+// denied), the function still returns the view of the file system positioned
+// on the resolved parent directory and the base name, together with the error
+// returned by Stat. This lets callers try a different operation on the parent
+// without resolving the path again.
+//
+// Errors resolving the intermediate components of name are returned with a nil
+// file system.
+func ResolveParentOrError(fsys fs.FS, name string) (fs.FS, string, error) {
+	dir, base, err := Lookup(fsys, name)
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err := fs.Stat(dir, base); err != nil {
+		return dir, base, err
+	}
+	return dir, base, nil
+}
+
+// FindUp looks for target in start and each of its parent directories, up to
+// the root of fsys, and returns the path of the first directory where target
+// exists, following symbolic links. This is useful to locate the root of a
+// project from one of its sub-directories, for example by looking for a .git
+// directory.
+//
+// The start directory is resolved first, and the search walks up the parents
+// of its canonical path. The function returns an error wrapping fs.ErrNotExist
+// if target was not found.
+func FindUp(fsys fs.FS, start, target string) (string, error) {
+	r, err := defaultOptions.resolve(fsys, start)
+	if err != nil {
+		return "", err
+	}
+	for dir := r.path(); ; dir = path.Dir(dir) {
+		_, err := Stat(fsys, path.Join(dir, target))
+		if err == nil {
+			return dir, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+		if dir == "." {
+			return "", &fs.PathError{Op: "findup", Path: target, Err: fs.ErrNotExist}
+		}
+	}
+}
+
+// ResolvedEntry is a directory entry returned by ResolveDir.
+type ResolvedEntry struct {
+	// Name is the name of the entry in the directory.
+	Name string
+	// Entry is the directory entry, it describes the symbolic link itself
+	// rather than its target when the entry is a link.
+	Entry fs.DirEntry
+	// Target is the canonical path that the entry resolves to, relative to
+	// the root of the file system, if the entry is a symbolic link. It is
+	// empty for other entries.
+	Target string
+}
+
+// ResolveDir reads the entries of dir like ReadDir, and resolves the targets
+// of the symbolic links that it contains. Like LinkTargets, dangling links are
+// reported with the path of the file that they would resolve to if it existed.
+//
+// When links cannot be resolved, the function still returns all the entries,
+// leaving their target empty, and the problems are reported in the returned
+// error, joining a *LinkError for each failed link.
+func ResolveDir(fsys fs.FS, dir string) ([]ResolvedEntry, error) {
+	r, err := defaultOptions.resolve(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	dir = r.path()
+
+	entries, err := ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var errs []error
+	resolved := make([]ResolvedEntry, len(entries))
+	for i, entry := range entries {
+		resolved[i] = ResolvedEntry{Name: entry.Name(), Entry: entry}
+		if entry.Type() != fs.ModeSymlink {
+			continue
+		}
+		name := path.Join(dir, entry.Name())
+		r, err := defaultOptions.resolve(fsys, name)
+		if err != nil {
+			link, _ := ReadLink(fsys, name)
+			errs = append(errs, &LinkError{Path: name, Link: link, Err: err})
+			continue
+		}
+		resolved[i].Target = r.path()
+	}
+	return resolved, errors.Join(errs...)
+}
+
+// StablePrefix returns the longest leading prefix of name which contains no
+// symbolic links, which is the portion of the path that resolves to the same
+// directory regardless of the targets of links. This is useful to key caches
+// on link-free sub-trees.
+//
+// The function returns "." if the first component of name is a link, and name
+// itself if the path contains no links. The path must resolve successfully.
+func StablePrefix(fsys fs.FS, name string) (string, error) {
+	prefix := name
+	found := false
+	_, _, err := LookupWith(fsys, name, func(o *options) {
+		o.onLink = func(step LinkStep) {
+			if !found {
+				prefix, found = path.Dir(step.Path), true
+			}
+		}
+	})
+	if err != nil {
+		return "", err
+	}
+	return prefix, nil
+}
+
+// CanonicalParent resolves the directory containing name in fsys, following
+// symbolic links, and returns its canonical path. The final component of name
+// is not resolved, it may not exist or be a symbolic link, which makes the
+// function useful to determine where a new file would be created by joining
+// the returned path with path.Base(name).
+func CanonicalParent(fsys fs.FS, name string) (string, error) {
+	r, err := defaultOptions.resolveFollow(fsys, name, false)
+	if err != nil {
+		return "", err
+	}
+	return path.Dir(r.path()), nil
+}
+
+// ResolveWithRoot resolves rootPath in parent following symbolic links, then
+// resolves name in the resolved directory like Resolve, with the given options.
+//
+// Unlike SubFS, the resolved directory becomes the root of the resolution of
+// name: links pointing above it are clamped to it. This is useful when the
+// root of a file system is a sub-tree of a larger file system reached through
+// a symbolic link. The path of the returned result is relative to the
+// resolved root.
+func ResolveWithRoot(parent fs.FS, rootPath, name string, opts ...Option) (ResolveResult, error) {
+	r, err := defaultOptions.resolve(parent, rootPath)
+	if err != nil {
+		return ResolveResult{}, err
+	}
+	info, err := fs.Stat(r.fsys, r.base)
+	if err != nil {
+		return ResolveResult{}, err
+	}
+	if !info.IsDir() {
+		return ResolveResult{}, &fs.PathError{Op: "lookup", Path: rootPath, Err: errNotDir}
+	}
+	root, err := subDir(r.fsys, r.base)
+	if err != nil {
+		return ResolveResult{}, err
+	}
+	return Resolve(root, name, opts...)
+}
+
+// Contains reports whether the directory at dir in fsys contains an entry named
+// child. The dir argument is resolved following symbolic links, but child is
+// not: the function returns true if child is a dangling link.
+//
+// The child argument must be a single path component, otherwise an error
+// wrapping fs.ErrInvalid is returned. The entry is looked up with Lstat if the
+// file system supports it, or by reading the entries of the directory.
+func Contains(fsys fs.FS, dir, child string) (bool, error) {
+	if !fs.ValidPath(child) || child == "." || strings.Contains(child, "/") {
+		return false, &fs.PathError{Op: "contains", Path: child, Err: fs.ErrInvalid}
+	}
+	sub, err := Sub(fsys, dir)
+	if err != nil {
+		return false, err
+	}
+	if f, ok := sub.(interface {
+		Lstat(string) (fs.FileInfo, error)
+	}); ok {
+		_, err := f.Lstat(child)
+		if err == nil {
+			return true, nil
+		}
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	entries, err := fs.ReadDir(sub, ".")
+	if err != nil {
+		return false, err
+	}
+	for _, entry := range entries {
+		if entry.Name() == child {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// LookupViews is like Lookup but it returns the stack of views of the file
+// system traversed to reach the directory containing the resolved file, from
+// the root of fsys to the view positioned on the parent directory, alongside
+// the base name of the file in the last view.
+//
+// The stack reflects the canonical path of the parent directory: when links
+// walk back up the tree, the views of the directories that they leave are
+// removed. This is useful for callers which need to operate at intermediate
+// levels of the tree without resolving their paths again, which is only valid
+// as long as the file system is not modified.
+func LookupViews(fsys fs.FS, name string) ([]fs.FS, string, error) {
+	// The stack of views is only constructed when walking the path, which is
+	// otherwise skipped on file systems which do not support symbolic links.
+	r, err := newOptions([]Option{func(o *options) { o.forceWalk = true }}).resolve(fsys, name)
+	if err != nil {
+		return nil, "", err
+	}
+	views := make([]fs.FS, 0, len(r.walk)+1)
+	views = append(views, r.walk...)
+	return append(views, r.fsys), r.base, nil
+}
@@ -0,0 +1,560 @@
+package fspath_test
+
+import (
+	"errors"
+	"io/fs"
+	"reflect"
+	"testing"
+
+	"github.com/stealthrocket/fspath"
+	"github.com/stealthrocket/fstest"
+)
+
+func TestResolveAtRoot(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a/b/x": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../../../..")},
+		"a/b/y": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../d")},
+		"a/d":   &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+	}
+
+	for _, test := range [...]struct {
+		name   string
+		path   string
+		atRoot bool
+	}{
+		{name: "a/b/x", path: ".", atRoot: true},
+		{name: "a/b/x/a/d", path: "a/d", atRoot: true},
+		{name: "a/b/y", path: "a/d", atRoot: false},
+		{name: "a/d", path: "a/d", atRoot: false},
+	} {
+		r, err := fspath.Resolve(fsys, test.name)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if r.Path != test.path {
+			t.Errorf("%s: wrong path: want=%q got=%q", test.name, test.path, r.Path)
+		}
+		if r.AtRoot != test.atRoot {
+			t.Errorf("%s: wrong root marker: want=%t got=%t", test.name, test.atRoot, r.AtRoot)
+		}
+		if _, err := fs.Stat(r.FS, r.Base); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestResolveRootLevelDotDot(t *testing.T) {
+	fsys := fstest.MapFS{
+		"r":   &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("..")},
+		"s":   &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../../x")},
+		"x/y": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+	}
+
+	for _, test := range [...]struct {
+		name string
+		path string
+	}{
+		{name: "r", path: "."},
+		{name: "r/x/y", path: "x/y"},
+		{name: "r/r/r/x", path: "x"},
+		{name: "r/s/y", path: "x/y"},
+		{name: "s", path: "x"},
+		{name: "s/y", path: "x/y"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			r, err := fspath.Resolve(fsys, test.name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r.Path != test.path {
+				t.Errorf("wrong path: want=%q got=%q", test.path, r.Path)
+			}
+			if !r.AtRoot {
+				t.Error("resolution did not walk back to the root")
+			}
+			if _, err := fs.Stat(r.FS, r.Base); err != nil {
+				t.Error(err)
+			}
+
+			// Every link points above the root, they must all be clamped.
+			steps, err := fspath.ResolveTrace(fsys, test.name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, step := range steps {
+				if !step.Clamped {
+					t.Errorf("link %s was not clamped: %+v", step.Path, step)
+				}
+			}
+		})
+	}
+}
+
+func TestResolveDotDotTargets(t *testing.T) {
+	for _, test := range [...]struct {
+		name string
+		link string
+		path string
+	}{
+		{name: "l", link: "..", path: "."},
+		{name: "l", link: "../..", path: "."},
+		{name: "a/l", link: "..", path: "."},
+		{name: "a/l", link: "../..", path: "."},
+		{name: "a/b/l", link: "..", path: "a"},
+		{name: "a/b/l", link: "../..", path: "."},
+		{name: "a/b/l", link: "../../..", path: "."},
+		{name: "a/b/c/l", link: "..", path: "a/b"},
+		{name: "a/b/c/l", link: "../..", path: "a"},
+		{name: "a/b/c/l", link: "../../..", path: "."},
+	} {
+		fsys := fstest.MapFS{
+			test.name: &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte(test.link)},
+		}
+
+		r, err := fspath.Resolve(fsys, test.name)
+		if err != nil {
+			t.Errorf("%s -> %s: %v", test.name, test.link, err)
+			continue
+		}
+		if r.Path != test.path {
+			t.Errorf("%s -> %s: wrong path: want=%q got=%q", test.name, test.link, test.path, r.Path)
+		}
+		// The resolution is positioned on the directory that the link walked
+		// back to, which is referenced by the base ".".
+		if r.Base != "." {
+			t.Errorf("%s -> %s: wrong base: %q", test.name, test.link, r.Base)
+		}
+		info, err := fs.Stat(r.FS, r.Base)
+		if err != nil {
+			t.Error(err)
+		} else if !info.IsDir() {
+			t.Errorf("%s -> %s: not a directory", test.name, test.link)
+		}
+	}
+}
+
+func TestResolvePath(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a":     &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"a/b":   &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../c")},
+		"a/c/d": &fstest.MapFile{Mode: 0600, Data: []byte("Hello World!")},
+		"c/d":   &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+	}
+
+	for _, test := range [...]struct {
+		name  string
+		infos []string
+	}{
+		{name: "a/c/d", infos: []string{"a", "c", "d"}},
+		{name: "a/b/d", infos: []string{"a", "c", "d"}},
+		{name: "c", infos: []string{"c"}},
+	} {
+		infos, err := fspath.ResolvePath(fsys, test.name)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		names := make([]string, len(infos))
+		for i, info := range infos {
+			names[i] = info.Name()
+		}
+		if !reflect.DeepEqual(names, test.infos) {
+			t.Errorf("%s: wrong infos: want=%q got=%q", test.name, test.infos, names)
+		}
+	}
+
+	infos, err := fspath.ResolvePath(fsys, "a/c/d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := infos[len(infos)-1].Mode(); mode != 0600 {
+		t.Errorf("wrong mode of the resolved file: %v", mode)
+	}
+}
+
+func TestResolveDangling(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a":   &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"a/b": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../c/e")},
+		"a/f": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../c/d")},
+		"c/d": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+	}
+
+	dir, base, err := fspath.ResolveDangling(fsys, "a/b")
+	if !errors.Is(err, fspath.ErrDangling) {
+		t.Fatalf("expected ErrDangling, got %v", err)
+	}
+	if base != "e" {
+		t.Errorf("wrong base name: want=%q got=%q", "e", base)
+	}
+	if _, err := fs.Stat(dir, "d"); err != nil {
+		t.Errorf("expected the file system to be positioned on c: %v", err)
+	}
+
+	if _, base, err := fspath.ResolveDangling(fsys, "a/f"); err != nil {
+		t.Error(err)
+	} else if base != "d" {
+		t.Errorf("wrong base name: want=%q got=%q", "d", base)
+	}
+
+	if _, _, err := fspath.ResolveDangling(fsys, "a/x/y"); errors.Is(err, fspath.ErrDangling) || err == nil {
+		t.Errorf("expected an error for a missing directory, got %v", err)
+	}
+}
+
+func TestFindUp(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a":             &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"a/b":           &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../c/d/e")},
+		"c/.git/config": &fstest.MapFile{Mode: 0644},
+		"c/d/e/f/g":     &fstest.MapFile{Mode: 0644},
+	}
+
+	for _, test := range [...]struct {
+		start string
+		found string
+	}{
+		{start: "c/d/e/f", found: "c"},
+		{start: "c", found: "c"},
+		// The search walks up the canonical path of the start directory.
+		{start: "a/b/f", found: "c"},
+	} {
+		found, err := fspath.FindUp(fsys, test.start, ".git")
+		if err != nil {
+			t.Errorf("%s: %v", test.start, err)
+			continue
+		}
+		if found != test.found {
+			t.Errorf("%s: want=%q got=%q", test.start, test.found, found)
+		}
+	}
+
+	if _, err := fspath.FindUp(fsys, "a", ".git"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected fs.ErrNotExist, got %v", err)
+	}
+}
+
+func TestResolveRootLink(t *testing.T) {
+	for _, test := range [...]struct {
+		link    string
+		path    string
+		atRoot  bool
+		clamped bool
+	}{
+		{link: "c/d", path: "c/d"},
+		{link: "./c/d", path: "c/d"},
+		{link: "../c/d", path: "c/d", atRoot: true, clamped: true},
+		{link: "../../c/d", path: "c/d", atRoot: true, clamped: true},
+		{link: "c/../../c/d", path: "c/d", atRoot: true, clamped: true},
+	} {
+		fsys := fstest.MapFS{
+			"b":   &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte(test.link)},
+			"c/d": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+		}
+
+		r, err := fspath.Resolve(fsys, "b")
+		if err != nil {
+			t.Errorf("%s: %v", test.link, err)
+			continue
+		}
+		if r.Path != test.path {
+			t.Errorf("%s: wrong path: want=%q got=%q", test.link, test.path, r.Path)
+		}
+		if r.AtRoot != test.atRoot {
+			t.Errorf("%s: wrong root marker: want=%t got=%t", test.link, test.atRoot, r.AtRoot)
+		}
+		if _, err := fs.Stat(r.FS, r.Base); err != nil {
+			t.Errorf("%s: %v", test.link, err)
+		}
+
+		steps, err := fspath.ResolveTrace(fsys, "b")
+		if err != nil {
+			t.Errorf("%s: %v", test.link, err)
+			continue
+		}
+		if len(steps) != 1 || steps[0].Clamped != test.clamped || steps[0].Target != test.path {
+			t.Errorf("%s: wrong steps: %+v", test.link, steps)
+		}
+	}
+
+	// Absolute targets are not supported by the ReadLinkFS proposal, they are
+	// reported as missing files rather than resolved against the root.
+	fsys := fstest.MapFS{
+		"b":   &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("/c/d")},
+		"c/d": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+	}
+	if _, err := fspath.Resolve(fsys, "b"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected fs.ErrNotExist, got %v", err)
+	}
+}
+
+func TestResolveDir(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a":          &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("b")},
+		"b/file":     &fstest.MapFile{Mode: 0644},
+		"b/dir/x":    &fstest.MapFile{Mode: 0644},
+		"b/link":     &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../c/d")},
+		"b/dangling": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("missing")},
+		"c/d":        &fstest.MapFile{Mode: 0644},
+	}
+
+	entries, err := fspath.ResolveDir(fsys, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type entry struct {
+		name   string
+		mode   fs.FileMode
+		target string
+	}
+	var got []entry
+	for _, e := range entries {
+		got = append(got, entry{e.Name, e.Entry.Type(), e.Target})
+	}
+	want := []entry{
+		{"dangling", fs.ModeSymlink, "b/missing"},
+		{"dir", fs.ModeDir, ""},
+		{"file", 0, ""},
+		{"link", fs.ModeSymlink, "c/d"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong entries:\nwant=%+v\ngot= %+v", want, got)
+	}
+}
+
+func TestStablePrefix(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a":     &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"a/b":   &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../c")},
+		"c/d/e": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../f")},
+		"c/f":   &fstest.MapFile{Mode: 0644},
+		"g":     &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("c")},
+	}
+
+	for _, test := range []struct {
+		name   string
+		prefix string
+	}{
+		{name: "a/b/d", prefix: "a"},
+		{name: "a/b/d/e", prefix: "a"},
+		{name: "c/d/e", prefix: "c/d"},
+		{name: "c/f", prefix: "c/f"},
+		{name: "g/f", prefix: "."},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			prefix, err := fspath.StablePrefix(fsys, test.name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if prefix != test.prefix {
+				t.Errorf("wrong prefix: want=%q got=%q", test.prefix, prefix)
+			}
+		})
+	}
+}
+
+func TestCanonicalParent(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a":       &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"a/b":     &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../c/d")},
+		"c/d/e":   &fstest.MapFile{Mode: 0644},
+		"c/d/new": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../../f")},
+	}
+
+	for _, test := range []struct {
+		name   string
+		parent string
+	}{
+		{name: "a/b/e", parent: "c/d"},
+		{name: "a/b/missing", parent: "c/d"},
+		{name: "a/b/new", parent: "c/d"},
+		{name: "a/b", parent: "a"},
+		{name: "a", parent: "."},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			parent, err := fspath.CanonicalParent(fsys, test.name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if parent != test.parent {
+				t.Errorf("wrong parent: want=%q got=%q", test.parent, parent)
+			}
+		})
+	}
+
+	if _, err := fspath.CanonicalParent(fsys, "x/y/z"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected fs.ErrNotExist, got %v", err)
+	}
+}
+
+func TestResolveWithRoot(t *testing.T) {
+	parent := fstest.MapFS{
+		"current":             &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("releases/v2")},
+		"releases/v2/bin/app": &fstest.MapFile{Mode: 0755, Data: []byte("v2")},
+		"releases/v2/escape":  &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../../../secret")},
+		"releases/v2/lib":     &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("bin")},
+		"secret":              &fstest.MapFile{Mode: 0600},
+	}
+
+	for _, test := range []struct {
+		name  string
+		path  string
+		found bool
+	}{
+		{name: "bin/app", path: "bin/app", found: true},
+		{name: "lib/app", path: "bin/app", found: true},
+		// The link is clamped to the resolved root instead of reaching the
+		// root of the parent file system.
+		{name: "escape", path: "secret", found: false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			r, err := fspath.ResolveWithRoot(parent, "current", test.name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r.Path != test.path {
+				t.Errorf("wrong path: want=%q got=%q", test.path, r.Path)
+			}
+			if _, err := fs.Stat(r.FS, r.Base); (err == nil) != test.found {
+				t.Errorf("wrong result of stat: %v", err)
+			}
+		})
+	}
+
+	if _, err := fspath.ResolveWithRoot(parent, "secret", "x"); !errors.Is(err, fs.ErrInvalid) {
+		t.Errorf("expected fs.ErrInvalid, got %v", err)
+	}
+}
+
+func TestContains(t *testing.T) {
+	files := fstest.MapFS{
+		"a":          &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"a/b":        &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../c")},
+		"c/file":     &fstest.MapFile{Mode: 0644},
+		"c/dangling": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("missing")},
+	}
+
+	for _, backend := range []struct {
+		name string
+		fsys fs.FS
+	}{
+		{name: "lstat", fsys: files},
+		{name: "readdir", fsys: &countFS{fsys: files}},
+	} {
+		t.Run(backend.name, func(t *testing.T) {
+			for _, test := range []struct {
+				child    string
+				contains bool
+			}{
+				{child: "file", contains: true},
+				{child: "dangling", contains: true},
+				{child: "missing", contains: false},
+			} {
+				contains, err := fspath.Contains(backend.fsys, "a/b", test.child)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if contains != test.contains {
+					t.Errorf("%s: want=%t got=%t", test.child, test.contains, contains)
+				}
+			}
+		})
+	}
+
+	if _, err := fspath.Contains(files, "a/b", "x/y"); !errors.Is(err, fs.ErrInvalid) {
+		t.Errorf("expected fs.ErrInvalid, got %v", err)
+	}
+}
+
+func TestResolveParentOrError(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a":     &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"a/b":   &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../c")},
+		"c/new": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+	}
+
+	dir, base, err := fspath.ResolveParentOrError(fsys, "a/b/missing")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected fs.ErrNotExist, got %v", err)
+	}
+	if dir == nil {
+		t.Fatal("the resolved parent was not returned")
+	}
+	if base != "missing" {
+		t.Errorf("wrong base name: %q", base)
+	}
+	// The parent can be used to access other files without resolving the
+	// path again.
+	b, err := fs.ReadFile(dir, "new")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "Hello World!" {
+		t.Errorf("wrong file content: %q", b)
+	}
+
+	if dir, _, err := fspath.ResolveParentOrError(fsys, "x/y/z"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected fs.ErrNotExist, got %v", err)
+	} else if dir != nil {
+		t.Error("a parent was returned for a missing intermediate directory")
+	}
+
+	if _, _, err := fspath.ResolveParentOrError(fsys, "a/b/new"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestLookupViews(t *testing.T) {
+	files := fstest.MapFS{
+		"a":   &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"a/b": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../../c")},
+		"a/c": &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"c/d": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+	}
+
+	for _, backend := range []struct {
+		name string
+		fsys fs.FS
+	}{
+		{name: "readlink", fsys: files},
+		{name: "plain", fsys: plainFS{files}},
+	} {
+		for _, test := range []struct {
+			name  string
+			views int
+		}{
+			{name: "c/d", views: 2},
+			{name: "a/c/d", views: 3},
+			{name: "a/b/d", views: 2},
+		} {
+			if backend.name == "plain" && test.name == "a/b/d" {
+				continue
+			}
+			t.Run(backend.name+"/"+test.name, func(t *testing.T) {
+				views, base, err := fspath.LookupViews(backend.fsys, test.name)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(views) != test.views {
+					t.Errorf("wrong number of views: want=%d got=%d", test.views, len(views))
+				}
+				if base != "d" {
+					t.Errorf("wrong base name: %q", base)
+				}
+			})
+		}
+	}
+
+	// The views are positioned on each directory of the path.
+	views, _, err := fspath.LookupViews(files, "a/c/d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, name := range []string{"a", "c"} {
+		if _, err := fs.Stat(views[i], name); err != nil {
+			t.Errorf("view %d: %v", i, err)
+		}
+	}
+}
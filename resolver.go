@@ -0,0 +1,124 @@
+package fspath
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"runtime"
+	"sync"
+
+	"github.com/stealthrocket/fslink"
+)
+
+// Resolver resolves paths with a fixed set of options. Its methods behave like
+// the package functions of the same names, with the options applied as they
+// would be when passed to LookupWith.
+//
+// Constructing a Resolver once and reusing it avoids applying the options on
+// each call, which matters when resolving paths in hot loops. Resolvers are
+// safe to use concurrently if the options are (e.g. WithLinkFilter functions).
+type Resolver struct{ opts *options }
+
+// New returns a Resolver configured with the given options.
+func New(opts ...Option) *Resolver {
+	return &Resolver{newOptions(opts)}
+}
+
+// Lookup is like LookupWith with the options of the resolver.
+func (r *Resolver) Lookup(fsys fs.FS, name string) (fs.FS, string, error) {
+	res, err := r.opts.resolve(fsys, name)
+	return res.fsys, res.base, err
+}
+
+// Resolve is like the package function Resolve with the options of the
+// resolver.
+func (r *Resolver) Resolve(fsys fs.FS, name string) (ResolveResult, error) {
+	res, err := r.opts.resolve(fsys, name)
+	return res.result(), err
+}
+
+func (r *Resolver) Open(fsys fs.FS, name string) (fs.File, error) {
+	return lookup(r.opts, fsys, name, fs.FS.Open)
+}
+
+func (r *Resolver) Stat(fsys fs.FS, name string) (fs.FileInfo, error) {
+	if r.opts.noFollowFinal {
+		return r.opts.lstat(fsys, name)
+	}
+	return lookup(r.opts, fsys, name, fs.Stat)
+}
+
+func (r *Resolver) Sub(fsys fs.FS, name string) (fs.FS, error) {
+	return lookup(r.opts, fsys, name, fslink.Sub)
+}
+
+func (r *Resolver) ReadDir(fsys fs.FS, name string) ([]fs.DirEntry, error) {
+	return lookup(r.opts, fsys, name, fs.ReadDir)
+}
+
+func (r *Resolver) ReadFile(fsys fs.FS, name string) ([]byte, error) {
+	return lookup(r.opts, fsys, name, fs.ReadFile)
+}
+
+func (r *Resolver) ReadLink(fsys fs.FS, name string) (string, error) {
+	return r.opts.readLink(fsys, name)
+}
+
+func (r *Resolver) Lstat(fsys fs.FS, name string) (fs.FileInfo, error) {
+	return r.opts.lstat(fsys, name)
+}
+
+// Warm resolves names in fsys concurrently, populating the caches of the
+// resolver (see WithLinkCache and WithDirCache) so the first resolutions of
+// these paths made afterwards do not pay the cost of filling them. The number
+// of concurrent resolutions is bounded by runtime.GOMAXPROCS.
+//
+// The failure to resolve a name does not prevent the others from being warmed,
+// the errors are joined in the returned error, in the order of names. When ctx
+// is canceled, the remaining names are not resolved, and the context error is
+// joined to the returned error.
+func (r *Resolver) Warm(ctx context.Context, fsys fs.FS, names []string) error {
+	opts := *r.opts
+	opts.ctx = ctx
+
+	errs := make([]error, len(names))
+	work := make(chan int)
+	wg := sync.WaitGroup{}
+	workers := runtime.GOMAXPROCS(0)
+	if workers > len(names) {
+		workers = len(names)
+	}
+	for n := workers; n > 0; n-- {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				_, errs[i] = opts.resolve(fsys, names[i])
+			}
+		}()
+	}
+
+	var err error
+dispatch:
+	for i := range names {
+		select {
+		case work <- i:
+		case <-ctx.Done():
+			err = ctx.Err()
+			break dispatch
+		}
+	}
+	close(work)
+	wg.Wait()
+
+	// Resolutions interrupted by the cancellation of the context are already
+	// reported by the context error.
+	if err != nil {
+		for i := range errs {
+			if errors.Is(errs[i], err) {
+				errs[i] = nil
+			}
+		}
+	}
+	return errors.Join(append(errs, err)...)
+}
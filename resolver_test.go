@@ -0,0 +1,213 @@
+package fspath_test
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"testing"
+	"time"
+
+	"github.com/stealthrocket/fspath"
+	"github.com/stealthrocket/fstest"
+)
+
+func TestResolver(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a":   &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"a/b": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../c")},
+		"a/e": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("b")},
+		"c/d": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+	}
+
+	r := fspath.New(fspath.WithLinkFilter(func(prefix, target string) bool {
+		return prefix != "a/e"
+	}))
+
+	b, err := r.ReadFile(fsys, "a/b/d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "Hello World!" {
+		t.Errorf("wrong file content: %q", b)
+	}
+
+	res, err := r.Resolve(fsys, "a/b/d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Path != "c/d" {
+		t.Errorf("wrong path: want=%q got=%q", "c/d", res.Path)
+	}
+
+	// The filter configured on the resolver applies to all its methods.
+	if res, err := r.Resolve(fsys, "a/e"); err != nil {
+		t.Error(err)
+	} else if res.Path != "a/e" {
+		t.Errorf("filtered link was followed: %q", res.Path)
+	}
+	if link, err := r.ReadLink(fsys, "a/e"); err != nil {
+		t.Error(err)
+	} else if link != "b" {
+		t.Errorf("wrong link: %q", link)
+	}
+	if _, err := r.Stat(fsys, "a/x"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected fs.ErrNotExist, got %v", err)
+	}
+}
+
+func TestResolverFollowFinal(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a":      &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"a/dir":  &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../c")},
+		"a/file": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../c/d")},
+		"c/d":    &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+	}
+
+	follow := fspath.New(fspath.WithFollowFinal(true))
+	nofollow := fspath.New(fspath.WithFollowFinal(false))
+
+	t.Run("Stat", func(t *testing.T) {
+		if info, err := follow.Stat(fsys, "a/file"); err != nil {
+			t.Error(err)
+		} else if !info.Mode().IsRegular() {
+			t.Errorf("expected a regular file: %v", info.Mode())
+		}
+		if info, err := nofollow.Stat(fsys, "a/file"); err != nil {
+			t.Error(err)
+		} else if info.Mode().Type() != fs.ModeSymlink {
+			t.Errorf("expected a symbolic link: %v", info.Mode())
+		}
+	})
+
+	t.Run("Lookup", func(t *testing.T) {
+		if res, err := follow.Resolve(fsys, "a/file"); err != nil {
+			t.Error(err)
+		} else if res.Path != "c/d" {
+			t.Errorf("wrong path: want=c/d got=%s", res.Path)
+		}
+		if res, err := nofollow.Resolve(fsys, "a/file"); err != nil {
+			t.Error(err)
+		} else if res.Path != "a/file" {
+			t.Errorf("wrong path: want=a/file got=%s", res.Path)
+		}
+		// Links on intermediate components are always followed.
+		if res, err := nofollow.Resolve(fsys, "a/dir/d"); err != nil {
+			t.Error(err)
+		} else if res.Path != "c/d" {
+			t.Errorf("wrong path: want=c/d got=%s", res.Path)
+		}
+	})
+
+	for _, test := range []struct {
+		name string
+		path string
+		call func(*fspath.Resolver, string) error
+	}{
+		{name: "Open", path: "a/file", call: func(r *fspath.Resolver, name string) error {
+			f, err := r.Open(fsys, name)
+			if err == nil {
+				f.Close()
+			}
+			return err
+		}},
+		{name: "ReadFile", path: "a/file", call: func(r *fspath.Resolver, name string) error {
+			_, err := r.ReadFile(fsys, name)
+			return err
+		}},
+		{name: "ReadDir", path: "a/dir", call: func(r *fspath.Resolver, name string) error {
+			_, err := r.ReadDir(fsys, name)
+			return err
+		}},
+		{name: "Sub", path: "a/dir", call: func(r *fspath.Resolver, name string) error {
+			_, err := r.Sub(fsys, name)
+			return err
+		}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if err := test.call(follow, test.path); err != nil {
+				t.Error(err)
+			}
+			if err := test.call(nofollow, test.path); !errors.Is(err, fspath.ErrLoop) {
+				t.Errorf("expected fspath.ErrLoop, got %v", err)
+			}
+		})
+	}
+}
+
+func BenchmarkResolver(b *testing.B) {
+	fsys := fstest.MapFS{
+		"a":   &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"a/b": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../c")},
+		"c":   &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"c/d": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+	}
+	opts := []fspath.Option{
+		fspath.WithMaxOps(100),
+		fspath.WithLinkFilter(func(prefix, target string) bool { return true }),
+	}
+
+	b.Run("LookupWith", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, _, err := fspath.LookupWith(fsys, "a/b/d", opts...); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("Resolver", func(b *testing.B) {
+		b.ReportAllocs()
+		r := fspath.New(opts...)
+		for i := 0; i < b.N; i++ {
+			if _, _, err := r.Lookup(fsys, "a/b/d"); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestResolverWarm(t *testing.T) {
+	fsys := &countFS{fsys: fstest.MapFS{
+		"static":              &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"static/css":          &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"static/js":           &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"templates":           &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"static/css/main.css": &fstest.MapFile{Mode: 0644},
+		"static/js/main.js":   &fstest.MapFile{Mode: 0644},
+		"static/index.html":   &fstest.MapFile{Mode: 0644},
+		"templates/base.html": &fstest.MapFile{Mode: 0644},
+	}}
+	names := []string{
+		"static/css/main.css",
+		"static/js/main.js",
+		"static/index.html",
+		"missing/file",
+		"templates/base.html",
+	}
+	r := fspath.New(fspath.WithLinkCache(time.Hour))
+
+	err := r.Warm(context.Background(), fsys, names)
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected fs.ErrNotExist, got %v", err)
+	}
+
+	fsys.reset()
+	for _, name := range names {
+		if name == "missing/file" {
+			continue
+		}
+		if _, err := r.Stat(fsys, name); err != nil {
+			t.Error(err)
+		}
+	}
+	// Only the final components are probed again.
+	if n := fsys.count("readlink"); n != 4 {
+		t.Errorf("expected lookups to hit the cache, got %d calls to ReadLink", n)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := r.Warm(ctx, fsys, names); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
@@ -0,0 +1,26 @@
+package fspath
+
+import "io/fs"
+
+// SnapshotFS is the interface implemented by file systems which support taking
+// snapshots of their content.
+type SnapshotFS interface {
+	fs.FS
+	// Snapshot returns a consistent point-in-time view of the file system,
+	// which is not affected by later modifications.
+	Snapshot() (fs.FS, error)
+}
+
+// Snapshot returns a point-in-time view of fsys, which must implement
+// SnapshotFS, otherwise ErrUnsupported is returned.
+//
+// Paths resolved in the returned file system are not subject to races with
+// concurrent modifications of fsys, which makes the guarantees of Lookup hold
+// even when fsys is not read-only.
+func Snapshot(fsys fs.FS) (fs.FS, error) {
+	s, ok := fsys.(SnapshotFS)
+	if !ok {
+		return nil, &fs.PathError{Op: "snapshot", Path: ".", Err: ErrUnsupported}
+	}
+	return s.Snapshot()
+}
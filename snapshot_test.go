@@ -0,0 +1,60 @@
+package fspath_test
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+
+	"github.com/stealthrocket/fspath"
+	"github.com/stealthrocket/fstest"
+)
+
+// snapshotFS implements snapshots by copying its content.
+type snapshotFS struct{ fstest.MapFS }
+
+func (fsys snapshotFS) Snapshot() (fs.FS, error) {
+	snapshot := make(fstest.MapFS, len(fsys.MapFS))
+	for name, file := range fsys.MapFS {
+		f := *file
+		f.Data = append([]byte(nil), file.Data...)
+		snapshot[name] = &f
+	}
+	return snapshot, nil
+}
+
+func TestSnapshot(t *testing.T) {
+	fsys := snapshotFS{fstest.MapFS{
+		"a":   &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"a/b": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../c")},
+		"c/d": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+		"e/d": &fstest.MapFile{Mode: 0644, Data: []byte("How are you?")},
+	}}
+
+	snapshot, err := fspath.Snapshot(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fsys.MapFS["a/b"].Data = []byte("../e")
+
+	b, err := fspath.ReadFile(fsys, "a/b/d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "How are you?" {
+		t.Errorf("wrong file content: %q", b)
+	}
+
+	b, err = fspath.ReadFile(snapshot, "a/b/d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "Hello World!" {
+		t.Errorf("wrong file content in snapshot: %q", b)
+	}
+}
+
+func TestSnapshotUnsupported(t *testing.T) {
+	if _, err := fspath.Snapshot(fstest.MapFS{}); !errors.Is(err, fspath.ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported, got %v", err)
+	}
+}
@@ -0,0 +1,54 @@
+package fspath
+
+import "sync/atomic"
+
+// Stats is a set of counters accumulated by file systems created with the
+// WithStats option.
+type Stats struct {
+	Lookups int64 // number of paths resolved
+	Links   int64 // number of symbolic links followed
+	Clamped int64 // number of links rebased because they pointed above the root
+	Loops   int64 // number of resolutions that failed with ErrLoop
+	Ops     int64 // number of calls made to the underlying file system
+}
+
+type stats struct {
+	lookups atomic.Int64
+	links   atomic.Int64
+	clamped atomic.Int64
+	loops   atomic.Int64
+	ops     atomic.Int64
+}
+
+func (s *stats) add(links, clamped, ops int, loop bool) {
+	s.lookups.Add(1)
+	s.links.Add(int64(links))
+	s.clamped.Add(int64(clamped))
+	s.ops.Add(int64(ops))
+	if loop {
+		s.loops.Add(1)
+	}
+}
+
+func (s *stats) load() Stats {
+	if s == nil {
+		return Stats{}
+	}
+	return Stats{
+		Lookups: s.lookups.Load(),
+		Links:   s.links.Load(),
+		Clamped: s.clamped.Load(),
+		Loops:   s.loops.Load(),
+		Ops:     s.ops.Load(),
+	}
+}
+
+func (s *stats) reset() {
+	if s != nil {
+		s.lookups.Store(0)
+		s.links.Store(0)
+		s.clamped.Store(0)
+		s.loops.Store(0)
+		s.ops.Store(0)
+	}
+}
@@ -0,0 +1,61 @@
+package fspath_test
+
+import (
+	"io/fs"
+	"testing"
+
+	"github.com/stealthrocket/fspath"
+	"github.com/stealthrocket/fstest"
+)
+
+type statsFS interface {
+	fs.FS
+	Stats() fspath.Stats
+	ResetStats()
+}
+
+func TestWithStats(t *testing.T) {
+	fsys := fspath.RootFS(fstest.MapFS{
+		"a":   &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"a/b": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../../c")},
+		"a/x": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("y")},
+		"a/y": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("x")},
+		"c/d": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+	}, fspath.WithStats()).(statsFS)
+
+	if _, err := fs.ReadFile(fsys, "c/d"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.ReadFile(fsys, "a/b/d"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.Stat(fsys, "a/x"); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	stats := fsys.Stats()
+	if stats.Lookups != 3 {
+		t.Errorf("wrong number of lookups: %d", stats.Lookups)
+	}
+	if stats.Links != 3 {
+		t.Errorf("wrong number of links: %d", stats.Links)
+	}
+	if stats.Clamped != 1 {
+		t.Errorf("wrong number of clamped links: %d", stats.Clamped)
+	}
+	if stats.Loops != 1 {
+		t.Errorf("wrong number of loops: %d", stats.Loops)
+	}
+	if stats.Ops == 0 {
+		t.Error("no operations were counted")
+	}
+
+	fsys.ResetStats()
+	if stats := fsys.Stats(); stats != (fspath.Stats{}) {
+		t.Errorf("stats were not reset: %+v", stats)
+	}
+
+	if stats := fspath.RootFS(fstest.MapFS{}).(statsFS).Stats(); stats != (fspath.Stats{}) {
+		t.Errorf("stats collected without WithStats: %+v", stats)
+	}
+}
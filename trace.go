@@ -0,0 +1,93 @@
+package fspath
+
+import (
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// LinkStep describes a symbolic link followed during the resolution of a path.
+type LinkStep struct {
+	// Path of the symbolic link, relative to the file system root.
+	Path string
+	// Target of the link, as returned by ReadLink.
+	Link string
+	// Path that the link resolved to, relative to the file system root.
+	Target string
+	// Clamped is true if the link pointed above the root and was rebased off
+	// of it.
+	Clamped bool
+}
+
+// ResolveTrace resolves name in fsys like Lookup, and returns the list of
+// symbolic links that were followed, in order.
+//
+// When an error occurs, the steps followed until the error are returned.
+func ResolveTrace(fsys fs.FS, name string) ([]LinkStep, error) {
+	var steps []LinkStep
+	_, _, err := LookupWith(fsys, name, func(o *options) {
+		o.onLink = func(step LinkStep) { steps = append(steps, step) }
+	})
+	return steps, err
+}
+
+// Segment describes how a component of a path was resolved by Trace.
+type Segment struct {
+	// Name of the path component.
+	Name string
+	// Path that the component resolved to, relative to the file system root.
+	Path string
+	// Symlink is true if the component was a symbolic link, in which case Link
+	// is set to the target of the link.
+	Symlink bool
+	Link    string
+}
+
+// Trace resolves name in fsys and returns a list of segments describing how
+// each component of name was resolved.
+//
+// Unlike ResolveTrace which reports each link followed during the resolution,
+// Trace preserves the original components of name. When a component is a link
+// whose target contains other links, Path is the location after following all
+// of them.
+//
+// When an error occurs, the segments resolved until the error are returned.
+func Trace(fsys fs.FS, name string) ([]Segment, error) {
+	if err := checkPath(name); err != nil {
+		return nil, &fs.PathError{Op: "trace", Path: name, Err: err}
+	}
+	if name == "." {
+		return nil, nil
+	}
+
+	segments := make([]Segment, 0, strings.Count(name, "/")+1)
+	current := "."
+
+	// The parent directory is a canonical path, so the first link followed
+	// when resolving a prefix, if any, is its last component.
+	var step *LinkStep
+	opts := newOptions([]Option{func(o *options) {
+		o.onLink = func(s LinkStep) {
+			if step == nil {
+				step = &s
+			}
+		}
+	}})
+
+	for _, elem := range strings.Split(name, "/") {
+		prefix := path.Join(current, elem)
+		step = nil
+		r, err := opts.resolve(fsys, prefix)
+		if err != nil {
+			return segments, err
+		}
+		current = r.path()
+		segment := Segment{Name: elem, Path: current}
+		if step != nil && step.Path == prefix {
+			segment.Symlink, segment.Link = true, step.Link
+		}
+		segments = append(segments, segment)
+	}
+
+	return segments, nil
+}
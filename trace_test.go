@@ -0,0 +1,129 @@
+package fspath_test
+
+import (
+	"errors"
+	"io/fs"
+	"reflect"
+	"testing"
+
+	"github.com/stealthrocket/fspath"
+	"github.com/stealthrocket/fstest"
+)
+
+func TestResolveTrace(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a":   &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"a/b": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../../c")},
+		"a/c": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("b/d")},
+		"c/d": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+	}
+
+	for _, test := range [...]struct {
+		name  string
+		steps []fspath.LinkStep
+	}{
+		{
+			name: "c/d",
+		},
+
+		{
+			name: "a/b/d",
+			steps: []fspath.LinkStep{
+				{Path: "a/b", Link: "../../c", Target: "c", Clamped: true},
+			},
+		},
+
+		{
+			name: "a/c",
+			steps: []fspath.LinkStep{
+				{Path: "a/c", Link: "b/d", Target: "a/b/d"},
+				{Path: "a/b", Link: "../../c", Target: "c", Clamped: true},
+			},
+		},
+	} {
+		steps, err := fspath.ResolveTrace(fsys, test.name)
+		if err != nil {
+			t.Error(err)
+		}
+		if !reflect.DeepEqual(steps, test.steps) {
+			t.Errorf("%s: mismatch: want=%+v got=%+v", test.name, test.steps, steps)
+		}
+	}
+}
+
+func TestTrace(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a":   &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"a/b": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../../c")},
+		"c/d": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+	}
+
+	segments, err := fspath.Trace(fsys, "a/b/d")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []fspath.Segment{
+		{Name: "a", Path: "a"},
+		{Name: "b", Path: "c", Symlink: true, Link: "../../c"},
+		{Name: "d", Path: "c/d"},
+	}
+	if !reflect.DeepEqual(segments, want) {
+		t.Errorf("mismatch: want=%+v got=%+v", want, segments)
+	}
+}
+
+func TestTraceDotSlashTarget(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a/b/d": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+		"a/l1":  &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("./l2")},
+		"a/l2":  &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("./b")},
+	}
+
+	for _, test := range [...]struct {
+		name     string
+		segments []fspath.Segment
+	}{
+		{
+			name: "a/l2/d",
+			segments: []fspath.Segment{
+				{Name: "a", Path: "a"},
+				{Name: "l2", Path: "a/b", Symlink: true, Link: "./b"},
+				{Name: "d", Path: "a/b/d"},
+			},
+		},
+
+		{
+			name: "a/l1/d",
+			segments: []fspath.Segment{
+				{Name: "a", Path: "a"},
+				{Name: "l1", Path: "a/b", Symlink: true, Link: "./l2"},
+				{Name: "d", Path: "a/b/d"},
+			},
+		},
+	} {
+		segments, err := fspath.Trace(fsys, test.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(segments, test.segments) {
+			t.Errorf("%s: mismatch: want=%+v got=%+v", test.name, test.segments, segments)
+		}
+	}
+}
+
+func TestTraceInvalidPath(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a/b": &fstest.MapFile{Mode: 0644},
+	}
+
+	for _, name := range []string{"", "/a", "a/", "a//b", "a/../b"} {
+		_, err := fspath.Trace(fsys, name)
+		if !errors.Is(err, fs.ErrInvalid) {
+			t.Errorf("%q: expected fs.ErrInvalid, got %v", name, err)
+		}
+		if _, _, lookupErr := fspath.Lookup(fsys, name); !errors.Is(lookupErr, fs.ErrInvalid) {
+			t.Errorf("%q: expected fs.ErrInvalid from Lookup, got %v", name, lookupErr)
+		}
+	}
+}
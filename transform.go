@@ -0,0 +1,105 @@
+package fspath
+
+import (
+	"io"
+	"io/fs"
+	"path"
+
+	"github.com/stealthrocket/fslink"
+)
+
+// TransformFS returns a file system wrapping fsys and passing the content read
+// from regular files through transform, for example to decompress them.
+//
+// The wrapper preserves the structure of fsys: directories, ReadDir, ReadLink,
+// and Sub are passed through unchanged, so the functions of this package (e.g.
+// Lookup) resolve paths in the returned file system exactly like they would in
+// fsys, and the content of the files reached through symbolic links is
+// transformed as well. Since the size of the transformed content is unknown
+// until it is read, Stat reports the information of the underlying files.
+//
+// When the reader returned by transform implements io.Closer, it is closed
+// when closing the file, before the underlying file.
+func TransformFS(fsys fs.FS, transform func(io.Reader) io.Reader) fs.FS {
+	return transformFS{fsys, transform}
+}
+
+type transformFS struct {
+	fs        fs.FS
+	transform func(io.Reader) io.Reader
+}
+
+func (fsys transformFS) Open(name string) (fs.File, error) {
+	f, err := fsys.fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return f, nil
+	}
+	return &transformFile{File: f, r: fsys.transform(f)}, nil
+}
+
+func (fsys transformFS) Stat(name string) (fs.FileInfo, error) {
+	return fs.Stat(fsys.fs, name)
+}
+
+func (fsys transformFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return fs.ReadDir(fsys.fs, name)
+}
+
+func (fsys transformFS) ReadLink(name string) (string, error) {
+	return fslink.ReadLink(fsys.fs, name)
+}
+
+func (fsys transformFS) Lstat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "lstat", Path: name, Err: fs.ErrInvalid}
+	}
+	dir, base := path.Split(name)
+	if dir == "" {
+		return lstat(fsys.fs, base)
+	}
+	sub, err := fslink.Sub(fsys.fs, dir[:len(dir)-1])
+	if err != nil {
+		return nil, err
+	}
+	return lstat(sub, base)
+}
+
+func (fsys transformFS) Sub(name string) (fs.FS, error) {
+	sub, err := fslink.Sub(fsys.fs, name)
+	if err != nil {
+		return nil, err
+	}
+	return transformFS{sub, fsys.transform}, nil
+}
+
+type transformFile struct {
+	fs.File
+	r io.Reader
+}
+
+func (f *transformFile) Read(b []byte) (int, error) {
+	return f.r.Read(b)
+}
+
+func (f *transformFile) Close() error {
+	if c, ok := f.r.(io.Closer); ok {
+		c.Close()
+	}
+	return f.File.Close()
+}
+
+var (
+	_ fs.StatFS         = transformFS{}
+	_ fs.ReadDirFS      = transformFS{}
+	_ fs.SubFS          = transformFS{}
+	_ fslink.ReadLinkFS = transformFS{}
+	_ ReadLinkFS        = transformFS{}
+)
@@ -0,0 +1,67 @@
+package fspath_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/fs"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stealthrocket/fspath"
+	"github.com/stealthrocket/fstest"
+)
+
+func gunzip(r io.Reader) io.Reader {
+	z, err := gzip.NewReader(r)
+	if err != nil {
+		return iotest.ErrReader(err)
+	}
+	return z
+}
+
+func TestTransformFS(t *testing.T) {
+	var buf bytes.Buffer
+	z := gzip.NewWriter(&buf)
+	z.Write([]byte("Hello World!"))
+	z.Close()
+
+	fsys := fspath.TransformFS(fstest.MapFS{
+		"a/b": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../c")},
+		"c/d": &fstest.MapFile{Mode: 0644, Data: buf.Bytes()},
+	}, gunzip)
+
+	dir, base, err := fspath.Lookup(fsys, "a/b/d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		name string
+		fsys fs.FS
+		path string
+	}{
+		{name: "lookup", fsys: dir, path: base},
+		{name: "root", fsys: fspath.RootFS(fsys), path: "a/b/d"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			b, err := fs.ReadFile(test.fsys, test.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != "Hello World!" {
+				t.Errorf("wrong file content: %q", b)
+			}
+		})
+	}
+
+	if link, err := fspath.ReadLink(fsys, "a/b"); err != nil || link != "../c" {
+		t.Errorf("wrong link: %q (%v)", link, err)
+	}
+	entries, err := fspath.ReadDir(fsys, "a/b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "d" {
+		t.Errorf("wrong directory entries: %v", entries)
+	}
+}
@@ -0,0 +1,133 @@
+package fspath
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+)
+
+// DiskUsage resolves name in fsys following symbolic links, and returns the
+// total size of the regular files that it contains. When name is a regular
+// file, its size is returned.
+//
+// Symbolic links to directories found in the tree are followed, and each
+// directory is only counted once, even if it is reachable through multiple
+// links, which also prevents links pointing to one of their parents from
+// causing an infinite loop. Symbolic links to files are not counted unless
+// WithDereferenceLinks is passed, and dangling or looping links are ignored.
+// The options also configure the resolution of paths like they would when
+// passed to LookupWith.
+func DiskUsage(fsys fs.FS, name string, opts ...Option) (int64, error) {
+	o := newOptions(opts)
+	r, err := o.resolve(fsys, name)
+	if err != nil {
+		return 0, err
+	}
+	info, err := fs.Stat(r.fsys, r.base)
+	if err != nil {
+		return 0, err
+	}
+	if !info.IsDir() {
+		return regularSize(info), nil
+	}
+	u := &usage{opts: o, fsys: fsys, dirs: make(map[string]struct{})}
+	if o.dereferenceLinks {
+		u.files = make(map[string]struct{})
+	}
+	return u.sum(r.path())
+}
+
+// WithDereferenceLinks configures DiskUsage to count the size of the files that
+// symbolic links point to, like "du -L" does. Each file is counted once, even
+// if it is reachable through multiple links.
+func WithDereferenceLinks() Option {
+	return func(o *options) { o.dereferenceLinks = true }
+}
+
+// usage holds the state of DiskUsage. The directories are identified by their
+// canonical path relative to the root of fsys, which contains no symbolic
+// links, so they are accessed directly rather than resolved again.
+type usage struct {
+	opts *options
+	fsys fs.FS
+	dirs map[string]struct{}
+	// files is the set of files already counted, it is only used when links
+	// are dereferenced.
+	files map[string]struct{}
+}
+
+// sum uses an explicit stack of directories rather than recursion so the depth
+// of the tree is not bounded by the size of the goroutine stack.
+func (u *usage) sum(root string) (int64, error) {
+	var size int64
+	stack := []string{root}
+	u.dirs[root] = struct{}{}
+
+	for len(stack) > 0 {
+		dir := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		entries, err := fs.ReadDir(u.fsys, dir)
+		if err != nil {
+			return size, err
+		}
+		for _, entry := range entries {
+			name := path.Join(dir, entry.Name())
+			var info fs.FileInfo
+			switch entry.Type() {
+			case fs.ModeDir:
+				stack = u.push(stack, name)
+				continue
+			case fs.ModeSymlink:
+				r, err := u.opts.resolve(u.fsys, name)
+				if err == nil {
+					info, err = fs.Stat(r.fsys, r.base)
+				}
+				if err != nil {
+					if errors.Is(err, fs.ErrNotExist) || errors.Is(err, ErrLoop) {
+						continue
+					}
+					return size, err
+				}
+				if info.IsDir() {
+					stack = u.push(stack, r.path())
+					continue
+				}
+				if !u.opts.dereferenceLinks {
+					continue
+				}
+				name = r.path()
+			default:
+				if info, err = entry.Info(); err != nil {
+					return size, err
+				}
+			}
+			// Regular files are only reached once since each directory is
+			// visited once, but the targets of links may also be reached
+			// through their directory or other links.
+			if u.files != nil {
+				if _, seen := u.files[name]; seen {
+					continue
+				}
+				u.files[name] = struct{}{}
+			}
+			size += regularSize(info)
+		}
+	}
+	return size, nil
+}
+
+func (u *usage) push(stack []string, dir string) []string {
+	if _, seen := u.dirs[dir]; seen {
+		return stack
+	}
+	u.dirs[dir] = struct{}{}
+	return append(stack, dir)
+}
+
+func regularSize(info fs.FileInfo) int64 {
+	if !info.Mode().IsRegular() {
+		return 0
+	}
+	return info.Size()
+}
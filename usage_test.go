@@ -0,0 +1,49 @@
+package fspath_test
+
+import (
+	"io/fs"
+	"testing"
+
+	"github.com/stealthrocket/fspath"
+	"github.com/stealthrocket/fstest"
+)
+
+func TestDiskUsage(t *testing.T) {
+	fsys := fstest.MapFS{
+		"data/a":        &fstest.MapFile{Mode: 0644, Data: make([]byte, 1)},
+		"data/sub/b":    &fstest.MapFile{Mode: 0644, Data: make([]byte, 10)},
+		"data/sub/up":   &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("..")},
+		"data/other":    &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../other")},
+		"data/file":     &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../big")},
+		"data/again":    &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("sub/b")},
+		"data/dangling": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("missing")},
+		"data/loop":     &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("loop")},
+		"other/c":       &fstest.MapFile{Mode: 0644, Data: make([]byte, 100)},
+		"big":           &fstest.MapFile{Mode: 0644, Data: make([]byte, 1000)},
+		"link":          &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("data")},
+	}
+
+	for _, test := range []struct {
+		name string
+		path string
+		opts []fspath.Option
+		size int64
+	}{
+		{name: "file", path: "big", size: 1000},
+		{name: "directory", path: "data", size: 111},
+		{name: "link", path: "link", size: 111},
+		{name: "sub-directory", path: "link/sub", size: 111},
+		{name: "dereference", path: "link", opts: []fspath.Option{fspath.WithDereferenceLinks()}, size: 1111},
+		{name: "root", path: ".", opts: []fspath.Option{fspath.WithDereferenceLinks()}, size: 1111},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			size, err := fspath.DiskUsage(fsys, test.path, test.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if size != test.size {
+				t.Errorf("wrong size: want=%d got=%d", test.size, size)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package fspath
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/stealthrocket/fslink"
+)
+
+// LinkError is the error type used to report problems with symbolic links
+// found by Validate and ValidateAll.
+type LinkError struct {
+	Path string // path of the symbolic link
+	Link string // target of the symbolic link
+	Err  error  // ErrEscape, ErrLoop, fs.ErrNotExist, or the error reading the link
+}
+
+func (e *LinkError) Error() string {
+	return "link " + e.Path + " -> " + e.Link + ": " + e.Err.Error()
+}
+
+func (e *LinkError) Unwrap() error { return e.Err }
+
+// Validate walks fsys and checks that the symbolic links that it contains do
+// not escape the root, are not dangling, and do not create loops. The first
+// problem encountered is returned as a *LinkError.
+func Validate(fsys fs.FS) error {
+	return validate(fsys, func(err *LinkError) error { return err })
+}
+
+// ValidateAll is like Validate but it does not stop at the first problem, all
+// the errors found are joined in the returned error. Each of the joined errors
+// is a *LinkError.
+func ValidateAll(fsys fs.FS) error {
+	var errs []error
+	err := validate(fsys, func(err *LinkError) error {
+		errs = append(errs, err)
+		return nil
+	})
+	if err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+func validate(fsys fs.FS, report func(*LinkError) error) error {
+	return fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type() != fs.ModeSymlink {
+			return nil
+		}
+		if err := validateLink(fsys, name); err != nil {
+			return report(err)
+		}
+		return nil
+	})
+}
+
+func validateLink(fsys fs.FS, name string) *LinkError {
+	link, err := fslink.ReadLink(fsys, name)
+	if err != nil {
+		return &LinkError{Path: name, Err: err}
+	}
+	// The path of the link does not contain symbolic links since fs.WalkDir
+	// does not follow them, so the link escapes if the lexical resolution of
+	// its target goes above the root.
+	if target := path.Join(path.Dir(name), link); target == ".." || strings.HasPrefix(target, "../") {
+		return &LinkError{Path: name, Link: link, Err: ErrEscape}
+	}
+	if _, err := Stat(fsys, name); err != nil {
+		switch {
+		case errors.Is(err, ErrLoop):
+			err = ErrLoop
+		case errors.Is(err, fs.ErrNotExist):
+			err = fs.ErrNotExist
+		}
+		return &LinkError{Path: name, Link: link, Err: err}
+	}
+	return nil
+}
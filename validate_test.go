@@ -0,0 +1,73 @@
+package fspath_test
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+
+	"github.com/stealthrocket/fspath"
+	"github.com/stealthrocket/fstest"
+)
+
+func TestValidate(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a":   &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"a/b": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../c")},
+		"c/d": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+	}
+	if err := fspath.Validate(fsys); err != nil {
+		t.Error(err)
+	}
+	if err := fspath.ValidateAll(fsys); err != nil {
+		t.Error(err)
+	}
+
+	fsys["a/e"] = &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../../c")}
+	err := fspath.Validate(fsys)
+
+	var linkErr *fspath.LinkError
+	if !errors.As(err, &linkErr) {
+		t.Fatalf("expected *fspath.LinkError, got %v", err)
+	}
+	if linkErr.Path != "a/e" || !errors.Is(err, fspath.ErrEscape) {
+		t.Errorf("wrong error: %v", err)
+	}
+}
+
+func TestValidateAll(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a":   &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"a/b": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../c")},
+		"a/e": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../../c")},
+		"a/f": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("missing")},
+		"a/g": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("h")},
+		"a/h": &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("g")},
+		"c/d": &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+	}
+
+	err := fspath.ValidateAll(fsys)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+
+	errs := err.(interface{ Unwrap() []error }).Unwrap()
+	want := map[string]error{
+		"a/e": fspath.ErrEscape,
+		"a/f": fs.ErrNotExist,
+		"a/g": fspath.ErrLoop,
+		"a/h": fspath.ErrLoop,
+	}
+	if len(errs) != len(want) {
+		t.Errorf("wrong number of errors: want=%d got=%d (%v)", len(want), len(errs), err)
+	}
+	for _, err := range errs {
+		var linkErr *fspath.LinkError
+		if !errors.As(err, &linkErr) {
+			t.Errorf("expected *fspath.LinkError, got %v", err)
+			continue
+		}
+		if !errors.Is(err, want[linkErr.Path]) {
+			t.Errorf("%s: wrong error: want=%v got=%v", linkErr.Path, want[linkErr.Path], linkErr.Err)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package fspath
+
+import (
+	"io/fs"
+	"path"
+
+	"github.com/stealthrocket/fslink"
+)
+
+// WalkDirFSFunc is the type of the function called by WalkDirFS to visit each
+// file or directory.
+//
+// The function behaves like fs.WalkDirFunc, with the additional sub argument
+// exposing the view of the file system positioned on the directory when d is
+// a directory, or on the directory containing the file otherwise. Callers may
+// use it to access files without resolving their path from the root again.
+type WalkDirFSFunc func(path string, sub fs.FS, d fs.DirEntry, err error) error
+
+// WalkDirFS is like fs.WalkDir but it resolves root following symbolic links,
+// and passes the resolved view of the file system for each directory to fn.
+//
+// Symbolic links found in the tree are reported to fn but not followed. The
+// options configure the resolution of root like they would when passed to
+// LookupWith, and the walk itself when using WithDescendFilter.
+func WalkDirFS(fsys fs.FS, root string, fn WalkDirFSFunc, opts ...Option) error {
+	o := newOptions(opts)
+	r, err := o.resolve(fsys, root)
+	var info fs.FileInfo
+	if err == nil {
+		info, err = fs.Stat(r.fsys, r.base)
+	}
+	if err == nil {
+		err = walkDirFS(r.fsys, r.base, root, fs.FileInfoToDirEntry(info), fn, o.descendFilter)
+	} else {
+		// Like fs.WalkDir, errors on the root are reported to fn.
+		err = fn(root, nil, nil, err)
+	}
+	if err == fs.SkipDir || err == fs.SkipAll {
+		return nil
+	}
+	return err
+}
+
+// walkDirFrame is an entry of the explicit stack used by walkDirFS, holding
+// the entries of a directory which remain to be visited.
+type walkDirFrame struct {
+	sub     fs.FS
+	name    string
+	entries []fs.DirEntry
+}
+
+// walkDirFS walks the tree using an explicit stack rather than recursion so the
+// depth of the tree is not bounded by the size of the goroutine stack, which
+// matters when walking untrusted trees.
+func walkDirFS(dir fs.FS, base, name string, d fs.DirEntry, fn WalkDirFSFunc, filter func(string, fs.DirEntry) bool) error {
+	frame, err := visitDirFS(dir, base, name, d, fn)
+	if err != nil || frame == nil {
+		return err
+	}
+	stack := []walkDirFrame{*frame}
+
+	for len(stack) > 0 {
+		top := &stack[len(stack)-1]
+		if len(top.entries) == 0 {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		entry := top.entries[0]
+		top.entries = top.entries[1:]
+
+		name := path.Join(top.name, entry.Name())
+		if filter != nil && entry.IsDir() && !filter(name, entry) {
+			continue
+		}
+
+		frame, err := visitDirFS(top.sub, entry.Name(), name, entry, fn)
+		if err != nil {
+			if err == fs.SkipDir {
+				// Skip the remaining entries of the parent directory.
+				stack = stack[:len(stack)-1]
+				continue
+			}
+			return err
+		}
+		if frame != nil {
+			stack = append(stack, *frame)
+		}
+	}
+	return nil
+}
+
+// visitDirFS calls fn for the file at base in dir and, if it is a directory to
+// descend into, returns the frame holding its entries.
+func visitDirFS(dir fs.FS, base, name string, d fs.DirEntry, fn WalkDirFSFunc) (*walkDirFrame, error) {
+	if !d.IsDir() {
+		return nil, fn(name, dir, d, nil)
+	}
+
+	sub, err := fslink.Sub(dir, base)
+	if err != nil {
+		return nil, fn(name, dir, d, err)
+	}
+	if err := fn(name, sub, d, nil); err != nil {
+		if err == fs.SkipDir {
+			err = nil
+		}
+		return nil, err
+	}
+
+	entries, err := fs.ReadDir(sub, ".")
+	if err != nil {
+		if err = fn(name, sub, d, err); err != nil {
+			if err == fs.SkipDir {
+				err = nil
+			}
+			return nil, err
+		}
+	}
+	return &walkDirFrame{sub: sub, name: name, entries: entries}, nil
+}
+
+// WithDescendFilter configures WalkDirFS to call filter before visiting each
+// directory of the tree, except the root. When the function returns false, the
+// directory is skipped entirely: it is neither reported to the WalkDirFSFunc,
+// nor read, which saves the cost of calling ReadDir compared to returning
+// fs.SkipDir.
+func WithDescendFilter(filter func(path string, d fs.DirEntry) bool) Option {
+	return func(o *options) { o.descendFilter = filter }
+}
@@ -0,0 +1,156 @@
+package fspath_test
+
+import (
+	"errors"
+	"io/fs"
+	"reflect"
+	"runtime/debug"
+	"testing"
+
+	"github.com/stealthrocket/fspath"
+	"github.com/stealthrocket/fstest"
+)
+
+func TestWalkDirFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a":     &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"a/b":   &fstest.MapFile{Mode: 0666 | fs.ModeSymlink, Data: []byte("../c")},
+		"c/d":   &fstest.MapFile{Mode: 0644, Data: []byte("Hello World!")},
+		"c/e/f": &fstest.MapFile{Mode: 0644, Data: []byte("How are you?")},
+	}
+
+	var walk []string
+	files := make(map[string]string)
+
+	err := fspath.WalkDirFS(fsys, "a/b", func(path string, sub fs.FS, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		walk = append(walk, path)
+		if d.IsDir() {
+			_, err = fs.Stat(sub, ".")
+		} else {
+			var b []byte
+			b, err = fs.ReadFile(sub, d.Name())
+			files[path] = string(b)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"a/b", "a/b/d", "a/b/e", "a/b/e/f"}; !reflect.DeepEqual(walk, want) {
+		t.Errorf("mismatch: want=%q got=%q", want, walk)
+	}
+	if want := map[string]string{"a/b/d": "Hello World!", "a/b/e/f": "How are you?"}; !reflect.DeepEqual(files, want) {
+		t.Errorf("mismatch: want=%q got=%q", want, files)
+	}
+}
+
+func TestWalkDirFSMissingRoot(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a/b": &fstest.MapFile{Mode: 0644},
+	}
+
+	// The root is missing either when resolving its parent directory, or when
+	// reading its metadata once resolved. Both are reported to fn, which can
+	// choose to ignore the error.
+	for _, root := range []string{"missing", "missing/b", "a/missing"} {
+		var calls []string
+		err := fspath.WalkDirFS(fsys, root, func(path string, sub fs.FS, d fs.DirEntry, err error) error {
+			calls = append(calls, path)
+			if d != nil {
+				t.Errorf("%s: unexpected directory entry: %v", root, d)
+			}
+			if !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("%s: expected fs.ErrNotExist, got %v", root, err)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Errorf("%s: the error was not ignored: %v", root, err)
+		}
+		if want := []string{root}; !reflect.DeepEqual(calls, want) {
+			t.Errorf("%s: mismatch: want=%q got=%q", root, want, calls)
+		}
+	}
+}
+
+// deepFS is a synthetic tree of directories nested depth times, each one
+// containing a single directory named "d".
+type deepFS struct{ depth int }
+
+func (fsys deepFS) Open(name string) (fs.File, error) {
+	if name != "." {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return fstest.MapFS{}.Open(".")
+}
+
+func (fsys deepFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if name != "." {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	if fsys.depth == 0 {
+		return nil, nil
+	}
+	info, err := fs.Stat(fstest.MapFS{"d": &fstest.MapFile{Mode: 0755 | fs.ModeDir}}, "d")
+	if err != nil {
+		return nil, err
+	}
+	return []fs.DirEntry{fs.FileInfoToDirEntry(info)}, nil
+}
+
+func (fsys deepFS) Sub(dir string) (fs.FS, error) {
+	if dir != "d" || fsys.depth == 0 {
+		return nil, &fs.PathError{Op: "sub", Path: dir, Err: fs.ErrNotExist}
+	}
+	return deepFS{depth: fsys.depth - 1}, nil
+}
+
+func TestWalkDirFSDeep(t *testing.T) {
+	// Limit the size of goroutine stacks so a recursive walk of the tree would
+	// crash the test.
+	defer debug.SetMaxStack(debug.SetMaxStack(1 << 20))
+
+	const depth = 10000
+	n := 0
+	err := fspath.WalkDirFS(deepFS{depth: depth}, ".", func(path string, sub fs.FS, d fs.DirEntry, err error) error {
+		n++
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != depth+1 {
+		t.Errorf("wrong number of directories visited: want=%d got=%d", depth+1, n)
+	}
+}
+
+func TestWithDescendFilter(t *testing.T) {
+	fsys := &countFS{fsys: fstest.MapFS{
+		"a/b":              &fstest.MapFile{Mode: 0644},
+		"node_modules":     &fstest.MapFile{Mode: 0755 | fs.ModeDir},
+		"node_modules/x/y": &fstest.MapFile{Mode: 0644},
+		"c/d":              &fstest.MapFile{Mode: 0644},
+	}}
+
+	var walk []string
+	err := fspath.WalkDirFS(fsys, ".", func(path string, sub fs.FS, d fs.DirEntry, err error) error {
+		walk = append(walk, path)
+		return err
+	}, fspath.WithDescendFilter(func(path string, d fs.DirEntry) bool {
+		return d.Name() != "node_modules"
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{".", "a", "a/b", "c", "c/d"}; !reflect.DeepEqual(walk, want) {
+		t.Errorf("wrong walk: want=%q got=%q", want, walk)
+	}
+	if want, got := []string{".", "a", "c"}, fsys.calls("readdir"); !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong calls to ReadDir: want=%q got=%q", want, got)
+	}
+}
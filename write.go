@@ -0,0 +1,70 @@
+package fspath
+
+import (
+	"bufio"
+	"io"
+	"io/fs"
+)
+
+// OpenFileFS is the interface implemented by file systems which support opening
+// files with flags, for example to write to them.
+type OpenFileFS interface {
+	fs.FS
+	OpenFile(name string, flag int, perm fs.FileMode) (fs.File, error)
+}
+
+// OpenFile opens the file at name in fsys with the given flags (os.O_RDONLY,
+// os.O_CREATE, etc...), following symbolic links.
+//
+// The path is resolved with Lookup and the file is opened by calling OpenFile
+// on fsys with the canonical path, which must therefore implement OpenFileFS,
+// otherwise ErrUnsupported is returned.
+func OpenFile(fsys fs.FS, name string, flag int, perm fs.FileMode) (fs.File, error) {
+	f, ok := fsys.(OpenFileFS)
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: ErrUnsupported}
+	}
+	r, err := defaultOptions.resolve(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	return f.OpenFile(r.path(), flag, perm)
+}
+
+// OpenFileBuffered is like OpenFile but the returned value buffers writes in
+// memory, up to bufSize bytes, before writing them to the file. The buffer is
+// flushed when calling Close.
+//
+// The file opened must implement io.Writer, otherwise ErrUnsupported is
+// returned.
+func OpenFileBuffered(fsys fs.FS, name string, flag int, perm fs.FileMode, bufSize int) (io.WriteCloser, error) {
+	f, err := OpenFile(fsys, name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	w, ok := f.(io.Writer)
+	if !ok {
+		f.Close()
+		return nil, &fs.PathError{Op: "open", Path: name, Err: ErrUnsupported}
+	}
+	return &bufferedFile{File: f, w: bufio.NewWriterSize(w, bufSize)}, nil
+}
+
+type bufferedFile struct {
+	fs.File
+	w *bufio.Writer
+}
+
+func (f *bufferedFile) Write(b []byte) (int, error) { return f.w.Write(b) }
+
+func (f *bufferedFile) WriteString(s string) (int, error) { return f.w.WriteString(s) }
+
+func (f *bufferedFile) Flush() error { return f.w.Flush() }
+
+func (f *bufferedFile) Close() error {
+	err := f.w.Flush()
+	if cerr := f.File.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
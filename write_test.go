@@ -0,0 +1,53 @@
+package fspath_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stealthrocket/fspath"
+	"github.com/stealthrocket/fstest"
+)
+
+func TestOpenFileBuffered(t *testing.T) {
+	dir := makeDirTree(t)
+	fsys := fspath.DirFS(dir)
+
+	w, err := fspath.OpenFileBuffered(fsys, "a/b/e", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, chunk := range []string{"Hello", ", ", "World", "!"} {
+		if _, err := w.Write([]byte(chunk)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	b, err := os.ReadFile(filepath.Join(dir, "c", "e"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b) != 0 {
+		t.Errorf("writes were not buffered: %q", b)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err = os.ReadFile(filepath.Join(dir, "c", "e"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "Hello, World!" {
+		t.Errorf("wrong file content: %q", b)
+	}
+}
+
+func TestOpenFileUnsupported(t *testing.T) {
+	fsys := fstest.MapFS{}
+	if _, err := fspath.OpenFile(fsys, "a", os.O_WRONLY|os.O_CREATE, 0644); !errors.Is(err, fspath.ErrUnsupported) {
+		t.Errorf("expected fspath.ErrUnsupported, got %v", err)
+	}
+}